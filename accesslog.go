@@ -0,0 +1,246 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Route attributes which configure access logging for a route
+const (
+	AttrLogLevel  = "router.log.level"  // the slog.Level requests are logged at
+	AttrLogSample = "router.log.sample" // the fraction of requests which are logged, as a float64
+	AttrLogAttrs  = "router.log.attrs"  // the context attributes which are logged, as a []string
+)
+
+// Set the level requests to a route are logged at
+func LogLevel(l slog.Level) RouteOption {
+	return func(r *Route) *Route {
+		return r.Attr(AttrLogLevel, l)
+	}
+}
+
+// Set the fraction of requests to a route which are logged
+func LogSample(f float64) RouteOption {
+	return func(r *Route) *Route {
+		return r.Attr(AttrLogSample, f)
+	}
+}
+
+// Set the context attributes which are logged for requests to a route, in
+// addition to those configured for the access log
+func LogAttrs(keys ...string) RouteOption {
+	return func(r *Route) *Route {
+		return r.Attr(AttrLogAttrs, keys)
+	}
+}
+
+// An access log option
+type AccessLogOption func(AccessLogConfig) AccessLogConfig
+
+// Access log middleware configuration. Routes may override the level and
+// sample rate and log additional attributes; see LogLevel, LogSample and
+// LogAttrs.
+type AccessLogConfig struct {
+	// The logger requests are logged to. If nil, the default logger is used.
+	Logger *slog.Logger
+	// The level requests are logged at. Requests which produce a 5XX status
+	// are always logged at the error level.
+	Level slog.Level
+	// The fraction of requests which are logged. Values outside of the range
+	// (0, 1) log every request. Requests which produce a 5XX status are
+	// always logged.
+	Sample float64
+	// Context attributes which are logged, when they are present
+	Attrs []string
+	// The source of random values in [0, 1) which are used to sample requests.
+	// If nil, math/rand is used.
+	Rand func() float64
+}
+
+// Set the logger requests are logged to
+func WithAccessLogger(l *slog.Logger) AccessLogOption {
+	return func(c AccessLogConfig) AccessLogConfig {
+		c.Logger = l
+		return c
+	}
+}
+
+// Set the level requests are logged at
+func WithAccessLevel(l slog.Level) AccessLogOption {
+	return func(c AccessLogConfig) AccessLogConfig {
+		c.Level = l
+		return c
+	}
+}
+
+// Set the fraction of requests which are logged
+func WithAccessSample(f float64) AccessLogOption {
+	return func(c AccessLogConfig) AccessLogConfig {
+		c.Sample = f
+		return c
+	}
+}
+
+// Set the context attributes which are logged
+func WithAccessAttrs(keys ...string) AccessLogOption {
+	return func(c AccessLogConfig) AccessLogConfig {
+		c.Attrs = keys
+		return c
+	}
+}
+
+// Set the source of random values which are used to sample requests
+func WithAccessRand(f func() float64) AccessLogOption {
+	return func(c AccessLogConfig) AccessLogConfig {
+		c.Rand = f
+		return c
+	}
+}
+
+// AccessLog produces middleware which logs requests. The method, matched path
+// template, request path, status, response size, duration and origin address
+// of each request are logged, along with any configured context attributes.
+//
+// Requests are logged once their response entity has been closed, which the
+// router does after writing it, so that the size and duration describe the
+// entire response. Requests which fail with an error are logged once the
+// router's error handler has rendered the error and the result is written.
+// If the router is not serving the request, and nothing will render the
+// error, it is logged immediately with the status of an *HTTPError, or 500.
+func AccessLog(opts ...AccessLogOption) Middle {
+	conf := AccessLogConfig{Level: slog.LevelInfo}
+	for _, opt := range opts {
+		conf = opt(conf)
+	}
+	if conf.Rand == nil {
+		conf.Rand = rand.Float64
+	}
+	return accessLog{conf}
+}
+
+type accessLog struct {
+	conf AccessLogConfig
+}
+
+func (a accessLog) String() string {
+	return "AccessLog"
+}
+
+func (a accessLog) Wrap(h Handler) Handler {
+	return func(req *Request, cxt Context) (*Response, error) {
+		start := time.Now()
+		rsp, err := h(req, cxt)
+		if err != nil {
+			logged := onErrorResponse(req, func(rsp *Response) {
+				a.logResponse(req, cxt, rsp, start, err)
+			})
+			if !logged { // nothing will render the error; describe it as the default handler would
+				status := http.StatusInternalServerError
+				var herr *HTTPError
+				if errors.As(err, &herr) && herr.Status != 0 {
+					status = herr.Status
+				}
+				a.log(req, cxt, status, 0, time.Since(start), err)
+			}
+			return rsp, err
+		}
+		if rsp == nil {
+			a.log(req, cxt, http.StatusNoContent, 0, time.Since(start), nil)
+			return rsp, err
+		}
+		a.logResponse(req, cxt, rsp, start, nil)
+		return rsp, err
+	}
+}
+
+// Log a response once its entity, if it has one, has been written
+func (a accessLog) logResponse(req *Request, cxt Context, rsp *Response, start time.Time, err error) {
+	status := rsp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if rsp.Entity == nil {
+		a.log(req, cxt, status, 0, time.Since(start), err)
+		return
+	}
+	rsp.Entity = &countingReader{
+		ReadCloser: rsp.Entity,
+		done: func(n int64) {
+			a.log(req, cxt, status, n, time.Since(start), err)
+		},
+	}
+}
+
+func (a accessLog) log(req *Request, cxt Context, status int, size int64, dur time.Duration, err error) {
+	level := a.conf.Level
+	if v, ok := cxt.Attrs[AttrLogLevel].(slog.Level); ok {
+		level = v
+	}
+	if status >= 500 {
+		level = slog.LevelError
+	} else {
+		sample := a.conf.Sample
+		if v, ok := cxt.Attrs[AttrLogSample].(float64); ok {
+			sample = v
+		}
+		if sample > 0 && sample < 1 && a.conf.Rand() >= sample {
+			return
+		}
+	}
+
+	log := a.conf.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+	if !log.Enabled(req.Context(), level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("route", cxt.Path),
+		slog.String("path", req.URL.Path),
+		slog.Int("status", status),
+		slog.Int64("size", size),
+		slog.Duration("duration", dur),
+		slog.String("origin", req.OriginAddr()),
+	}
+	keys, _ := cxt.Attrs[AttrLogAttrs].([]string)
+	for _, k := range append(a.conf.Attrs[:len(a.conf.Attrs):len(a.conf.Attrs)], keys...) {
+		if v, ok := cxt.Attrs[k]; ok {
+			attrs = append(attrs, slog.Any(k, v))
+		}
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	log.LogAttrs(req.Context(), level, "Request", attrs...)
+}
+
+// An entity which counts the bytes read from it and reports the count once,
+// when it is closed
+type countingReader struct {
+	io.ReadCloser
+	n    int64
+	done func(int64)
+	once sync.Once
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(func() {
+		r.done(r.n)
+	})
+	return err
+}
@@ -0,0 +1,123 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var rnd []float64
+	sample := func() float64 {
+		v := rnd[0]
+		rnd = rnd[1:]
+		return v
+	}
+
+	r := New()
+	r.Use(AccessLog(WithAccessLogger(log), WithAccessAttrs("user"), WithAccessRand(sample)))
+	r.Use(MiddleFunc(func(h Handler) Handler {
+		return func(req *Request, cxt Context) (*Response, error) {
+			cxt.Attrs["user"] = "bob"
+			cxt.Attrs["team"] = "red"
+			return h(req, cxt)
+		}
+	}))
+	r.Add("/users/{id}", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusCreated).SetString("text/plain", "Hello")
+	}).Methods("GET").With(LogLevel(slog.LevelDebug), LogAttrs("team"))
+	r.Add("/fail", func(*Request, Context) (*Response, error) {
+		return nil, NewError(http.StatusConflict, "Conflict")
+	}).Methods("GET")
+	r.Add("/teapot", func(*Request, Context) (*Response, error) {
+		return nil, responderError{NewResponse(http.StatusTeapot)}
+	}).Methods("GET")
+	r.Add("/health", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "OK")
+	}).Methods("GET").With(LogSample(0.25))
+
+	entries := func() []map[string]interface{} {
+		var res []map[string]interface{}
+		for _, e := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if e == "" {
+				continue
+			}
+			var v map[string]interface{}
+			if assert.NoError(t, json.Unmarshal([]byte(e), &v)) {
+				res = append(res, v)
+			}
+		}
+		buf.Reset()
+		return res
+	}
+
+	req := httptest.NewRequest("GET", "/users/123", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if e := entries(); assert.Len(t, e, 1) {
+		assert.Equal(t, "DEBUG", e[0]["level"])
+		assert.Equal(t, "GET", e[0]["method"])
+		assert.Equal(t, "/users/{id}", e[0]["route"])
+		assert.Equal(t, "/users/123", e[0]["path"])
+		assert.Equal(t, float64(http.StatusCreated), e[0]["status"])
+		assert.Equal(t, float64(5), e[0]["size"])
+		assert.Equal(t, "10.0.0.1", e[0]["origin"])
+		assert.Equal(t, "bob", e[0]["user"])
+		assert.Equal(t, "red", e[0]["team"])
+		assert.Contains(t, e[0], "duration")
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/fail", nil))
+	if e := entries(); assert.Len(t, e, 1) {
+		assert.Equal(t, "INFO", e[0]["level"])
+		assert.Equal(t, float64(http.StatusConflict), e[0]["status"])
+		assert.Equal(t, float64(rec.Body.Len()), e[0]["size"])
+		assert.Nil(t, e[0]["team"])
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/teapot", nil))
+	if e := entries(); assert.Len(t, e, 1) {
+		assert.Equal(t, "INFO", e[0]["level"])
+		assert.Equal(t, float64(http.StatusTeapot), e[0]["status"])
+	}
+
+	rnd = []float64{0.5, 0.9, 0.1, 0.25}
+	for i := 0; i < 4; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	}
+	assert.Len(t, entries(), 1)
+}
+
+func TestAccessLogErrorHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := slog.New(slog.NewJSONHandler(buf, nil))
+
+	var calls int
+	r := New(WithErrorHandler(func(req *Request, err error) *Response {
+		calls++
+		rsp, _ := NewResponse(http.StatusServiceUnavailable).SetString("text/plain", "Unavailable")
+		return rsp
+	}))
+	r.Use(AccessLog(WithAccessLogger(log)))
+	r.Add("/fail", func(*Request, Context) (*Response, error) {
+		return nil, NewError(http.StatusConflict, "Conflict")
+	}).Methods("GET")
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+	assert.Equal(t, 1, calls)
+	var e map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &e)) {
+		assert.Equal(t, float64(http.StatusServiceUnavailable), e["status"])
+		assert.Equal(t, float64(len("Unavailable")), e["size"])
+	}
+}
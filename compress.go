@@ -0,0 +1,286 @@
+package router
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content encodings supported for compression
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingZstd    = "zstd"
+)
+
+// A compressor which can be flushed and reused
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Pools of compressors by encoding
+var compressors = map[string]*sync.Pool{
+	EncodingGzip: {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+	EncodingDeflate: {New: func() interface{} {
+		return zlib.NewWriter(nil) // 'deflate' is the zlib format; see RFC 9110, 8.4.1.2
+	}},
+	EncodingZstd: {New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}},
+}
+
+// A compression option
+type CompressOption func(CompressConfig) CompressConfig
+
+// Compression middleware configuration
+type CompressConfig struct {
+	// Encodings which may be used, in order of preference. When a client
+	// accepts more than one with the same quality, the earliest is used.
+	Encodings []string
+	// Responses smaller than this many bytes are not compressed. Streaming
+	// responses are compressed regardless of their size.
+	MinSize int64
+	// Media ranges which are not compressed, usually because they are
+	// already compressed
+	Skip []string
+}
+
+// Set the encodings which may be used, in order of preference. Unsupported
+// encodings are ignored.
+func WithCompressEncodings(e ...string) CompressOption {
+	return func(c CompressConfig) CompressConfig {
+		c.Encodings = e
+		return c
+	}
+}
+
+// Set the minimum size of responses which are compressed
+func WithCompressMinSize(n int64) CompressOption {
+	return func(c CompressConfig) CompressConfig {
+		c.MinSize = n
+		return c
+	}
+}
+
+// Set the media ranges which are not compressed
+func WithCompressSkipTypes(t ...string) CompressOption {
+	return func(c CompressConfig) CompressConfig {
+		c.Skip = t
+		return c
+	}
+}
+
+// Compress produces middleware which compresses response entities using an
+// encoding negotiated from the request's Accept-Encoding header. By default
+// zstd, gzip and deflate are supported, in that order of preference,
+// responses smaller than 1KB are not compressed, and neither are images,
+// audio, video or common archive formats.
+//
+// Responses which are already encoded, which have no entity, which specify
+// 'Cache-Control: no-transform', or which contain a byte range of the entity
+// are not compressed. Streaming responses are
+// compressed incrementally, and each chunk of the entity that is read is
+// flushed through the compressor so it is delivered without delay.
+func Compress(opts ...CompressOption) Middle {
+	conf := CompressConfig{
+		Encodings: []string{EncodingZstd, EncodingGzip, EncodingDeflate},
+		MinSize:   1024,
+		Skip: []string{
+			"image/*", "audio/*", "video/*", "font/woff2",
+			"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+			"application/x-bzip2", "application/x-7z-compressed", "application/x-rar-compressed",
+		},
+	}
+	for _, opt := range opts {
+		conf = opt(conf)
+	}
+	return compress{conf}
+}
+
+type compress struct {
+	conf CompressConfig
+}
+
+func (c compress) String() string {
+	return "Compress"
+}
+
+func (c compress) Wrap(h Handler) Handler {
+	return func(req *Request, cxt Context) (*Response, error) {
+		rsp, err := h(req, cxt)
+		if err != nil || rsp == nil || rsp.Entity == nil {
+			return rsp, err
+		}
+		if rsp.Header == nil {
+			rsp.Header = make(http.Header)
+		}
+		if rsp.Header.Get("Content-Encoding") != "" || strings.Contains(rsp.Header.Get("Cache-Control"), "no-transform") {
+			return rsp, nil
+		}
+		if rsp.Status == http.StatusPartialContent || rsp.Header.Get("Content-Range") != "" {
+			return rsp, nil // ranges describe the unencoded entity
+		}
+		if t := rsp.Header.Get("Content-Type"); t != "" && anyMediaMatches(c.conf.Skip, t) {
+			return rsp, nil
+		}
+
+		rsp.Header.Add("Vary", "Accept-Encoding")
+		enc := c.negotiate(req.Header.Values("Accept-Encoding"))
+		if enc == "" {
+			return rsp, nil
+		}
+		if !rsp.Streaming && c.conf.MinSize > 0 {
+			if v, err := strconv.ParseInt(rsp.Header.Get("Content-Length"), 10, 64); err == nil {
+				if v < c.conf.MinSize {
+					return rsp, nil
+				}
+			} else {
+				small, err := peekEntity(rsp, c.conf.MinSize)
+				if err != nil {
+					return nil, err
+				} else if small {
+					return rsp, nil
+				}
+			}
+		}
+
+		w := compressors[enc].Get().(compressor)
+		r := &compressReader{
+			src:   rsp.Entity,
+			chunk: make([]byte, 32*1024),
+			flush: rsp.Streaming,
+			pool:  compressors[enc],
+			enc:   w,
+		}
+		w.Reset(&r.buf)
+		rsp.Entity = r
+		rsp.Header.Set("Content-Encoding", enc)
+		rsp.Header.Del("Content-Length")
+		if etag := rsp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			rsp.Header.Set("ETag", "W/"+etag) // the encoded entity is not byte-for-byte identical
+		}
+		return rsp, nil
+	}
+}
+
+// Select the most acceptable encoding, or none if none are acceptable
+func (c compress) negotiate(accept []string) string {
+	if len(accept) == 0 {
+		return ""
+	}
+	ranges := parseQualified(strings.Join(accept, ","))
+	var (
+		best string
+		q    float64
+	)
+	for _, e := range c.conf.Encodings {
+		if _, ok := compressors[e]; !ok {
+			continue
+		}
+		if v := encodingQuality(ranges, e); v > q {
+			best, q = e, v
+		}
+	}
+	return best
+}
+
+// Determine the quality with which an encoding is accepted. An exact match
+// takes precedence over a wildcard.
+func encodingQuality(ranges []qualified, e string) float64 {
+	q := -1.0
+	for _, r := range ranges {
+		if r.value == e {
+			return r.q
+		} else if r.value == "*" && q < 0 {
+			q = r.q
+		}
+	}
+	if q < 0 {
+		return 0
+	}
+	return q
+}
+
+// Read up to the provided number of bytes of a response entity to determine
+// if it is smaller than that. The entity is replaced with one which produces
+// the same data.
+func peekEntity(rsp *Response, n int64) (bool, error) {
+	buf := &bytes.Buffer{}
+	_, err := io.CopyN(buf, rsp.Entity, n)
+	if err == io.EOF {
+		rsp.Entity.Close()
+		rsp.Entity = io.NopCloser(buf)
+		return true, nil
+	} else if err != nil {
+		rsp.Entity.Close()
+		return false, err
+	}
+	rsp.Entity = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(buf, rsp.Entity), rsp.Entity}
+	return false, nil
+}
+
+// An entity which compresses the data read from another entity
+type compressReader struct {
+	src   io.ReadCloser
+	enc   compressor
+	pool  *sync.Pool
+	buf   bytes.Buffer // compressed data which has not been read
+	chunk []byte
+	flush bool // flush the compressor after each chunk is written
+	eof   bool
+}
+
+func (r *compressReader) Read(p []byte) (int, error) {
+	if r.enc == nil {
+		return 0, io.ErrClosedPipe
+	}
+	for r.buf.Len() == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		n, err := r.src.Read(r.chunk)
+		if n > 0 {
+			if _, werr := r.enc.Write(r.chunk[:n]); werr != nil {
+				return 0, werr
+			}
+			if r.flush {
+				if ferr := r.enc.Flush(); ferr != nil {
+					return 0, ferr
+				}
+			}
+		}
+		if err == io.EOF {
+			if cerr := r.enc.Close(); cerr != nil {
+				return 0, cerr
+			}
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	return r.buf.Read(p)
+}
+
+func (r *compressReader) Close() error {
+	if r.enc != nil {
+		r.enc.Reset(nil)
+		r.pool.Put(r.enc)
+		r.enc = nil
+	}
+	return r.src.Close()
+}
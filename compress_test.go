@@ -0,0 +1,122 @@
+package router
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("Hello, compression. ", 200)
+
+	r := New()
+	r.Use(Compress())
+	r.Add("/large", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetHeader("ETag", `"abc"`).SetString("application/json", large)
+	}).Methods("GET")
+	r.Add("/small", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("application/json", "{}")
+	}).Methods("GET")
+	r.Add("/image", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("image/png", large)
+	}).Methods("GET")
+	r.Add("/stream", func(*Request, Context) (*Response, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			for i := 0; i < 3; i++ {
+				pw.Write([]byte("chunk "))
+			}
+			pw.Close()
+		}()
+		rsp := NewResponse(http.StatusOK).SetHeader("Content-Type", "text/plain").SetStreaming(true)
+		rsp.Entity = pr
+		return rsp, nil
+	}).Methods("GET")
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		"deflate": func(r io.Reader) (io.Reader, error) {
+			return zlib.NewReader(r)
+		},
+		"zstd": func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		},
+	}
+
+	tests := []struct {
+		Path     string
+		Accept   string
+		Encoding string
+		Expect   string
+	}{
+		{"/large", "gzip", "gzip", large},
+		{"/large", "gzip;q=0.5, deflate", "deflate", large},
+		{"/large", "gzip, deflate, br, zstd", "zstd", large},
+		{"/large", "*", "zstd", large},
+		{"/large", "*, zstd;q=0", "gzip", large},
+		{"/large", "br", "", large},
+		{"/large", "", "", large},
+		{"/small", "gzip", "", "{}"},
+		{"/image", "gzip", "", large},
+		{"/stream", "gzip", "gzip", "chunk chunk chunk "},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", e.Path, nil)
+		if e.Accept != "" {
+			req.Header.Set("Accept-Encoding", e.Accept)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, e.Path)
+		assert.Equal(t, e.Encoding, rec.Header().Get("Content-Encoding"), e.Path, e.Accept)
+		var body io.Reader = rec.Body
+		if e.Encoding != "" {
+			dec, err := decoders[e.Encoding](rec.Body)
+			if !assert.NoError(t, err) {
+				continue
+			}
+			body = dec
+			if e.Path == "/large" {
+				assert.Equal(t, `W/"abc"`, rec.Header().Get("ETag"))
+			}
+		}
+		data, err := io.ReadAll(body)
+		if assert.NoError(t, err) {
+			assert.Equal(t, e.Expect, string(data), e.Path, e.Accept)
+		}
+		if e.Path != "/image" {
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"), e.Path)
+		}
+	}
+}
+
+func TestCompressRange(t *testing.T) {
+	large := strings.Repeat("Hello, compression. ", 200)
+
+	r := New()
+	r.Use(Compress(WithCompressMinSize(1)))
+	r.Add("/partial", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusPartialContent).SetHeader("Content-Range", "bytes 0-3999/8000").SetString("text/plain", large)
+	}).Methods("GET")
+	r.Add("/unsatisfiable", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusRequestedRangeNotSatisfiable).SetHeader("Content-Range", "bytes */8000").SetString("text/plain", large)
+	}).Methods("GET")
+
+	for _, path := range []string{"/partial", "/unsatisfiable"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, "", rec.Header().Get("Content-Encoding"), path)
+		assert.Equal(t, large, rec.Body.String(), path)
+	}
+}
@@ -0,0 +1,236 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The route attribute which overrides the CORS configuration for a route, as
+// a []CORSOption
+const AttrCORS = "router.cors"
+
+// Override the CORS configuration for a route. The options are applied to
+// the configuration of the CORS middleware when it handles requests for the
+// route.
+func CORSPolicy(opts ...CORSOption) RouteOption {
+	return func(r *Route) *Route {
+		return r.Attr(AttrCORS, opts)
+	}
+}
+
+// A CORS option
+type CORSOption func(CORSConfig) CORSConfig
+
+// CORS middleware configuration
+type CORSConfig struct {
+	// Origins which are allowed. The origin '*' allows any origin, and origins
+	// may contain a single '*' which matches any non-empty text, for example,
+	// 'https://*.example.com'. Origins are compared without regard to case.
+	Origins []string
+	// Expressions which match allowed origins. Expressions must match the
+	// entire origin.
+	Patterns []*regexp.Regexp
+	// Allow requests with credentials
+	Credentials bool
+	// Request headers which are allowed. If empty, the headers requested by
+	// a preflight request are allowed.
+	Headers []string
+	// Response headers which are exposed to the client
+	Expose []string
+	// How long the result of a preflight request may be cached, if non-zero
+	MaxAge time.Duration
+}
+
+// Set the allowed origins
+func WithCORSOrigins(o ...string) CORSOption {
+	return func(c CORSConfig) CORSConfig {
+		c.Origins = o
+		return c
+	}
+}
+
+// Set expressions which match allowed origins. This function panics if an
+// expression is not valid.
+func WithCORSOriginPatterns(x ...string) CORSOption {
+	p := make([]*regexp.Regexp, len(x))
+	for i, e := range x {
+		p[i] = regexp.MustCompile("^(?:" + e + ")$")
+	}
+	return func(c CORSConfig) CORSConfig {
+		c.Patterns = p
+		return c
+	}
+}
+
+// Allow or disallow requests with credentials
+func WithCORSCredentials(on bool) CORSOption {
+	return func(c CORSConfig) CORSConfig {
+		c.Credentials = on
+		return c
+	}
+}
+
+// Set the allowed request headers
+func WithCORSAllowHeaders(h ...string) CORSOption {
+	return func(c CORSConfig) CORSConfig {
+		c.Headers = h
+		return c
+	}
+}
+
+// Set the response headers which are exposed to the client
+func WithCORSExposeHeaders(h ...string) CORSOption {
+	return func(c CORSConfig) CORSConfig {
+		c.Expose = h
+		return c
+	}
+}
+
+// Set how long preflight results may be cached
+func WithCORSMaxAge(d time.Duration) CORSOption {
+	return func(c CORSConfig) CORSConfig {
+		c.MaxAge = d
+		return c
+	}
+}
+
+// CORS produces middleware which implements cross-origin resource sharing.
+//
+// Preflight requests are answered with the methods registered on the routes
+// which match the request path, as produced by the router's automatic OPTIONS
+// handling; the router should be configured with WithAutoOptions(true) unless
+// it has routes which handle OPTIONS themselves. Routes may override the
+// configuration with CORSPolicy.
+//
+// Errors returned by handlers are passed on to outer middleware unchanged.
+// When the router renders an error with its error handler, CORS headers are
+// added to the response which describes it, so that it can be read by clients.
+func CORS(opts ...CORSOption) Middle {
+	var conf CORSConfig
+	for _, opt := range opts {
+		conf = opt(conf)
+	}
+	return cors{conf}
+}
+
+type cors struct {
+	conf CORSConfig
+}
+
+func (c cors) String() string {
+	return "CORS"
+}
+
+func (c cors) Wrap(h Handler) Handler {
+	return func(req *Request, cxt Context) (*Response, error) {
+		conf := c.conf
+		if opts, ok := cxt.Attrs[AttrCORS].([]CORSOption); ok {
+			for _, opt := range opts {
+				conf = opt(conf)
+			}
+		}
+
+		origin := req.Header.Get("Origin")
+		preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+		rsp, err := h(req, cxt)
+		if err != nil {
+			onErrorResponse(req, func(rsp *Response) {
+				conf.decorate(req, origin, preflight, rsp)
+			})
+			return rsp, err
+		}
+		if rsp == nil {
+			rsp = NewResponse(http.StatusNoContent)
+		}
+		if rsp.Header == nil {
+			rsp.Header = make(http.Header)
+		}
+		conf.decorate(req, origin, preflight, rsp)
+		return rsp, nil
+	}
+}
+
+// Add CORS headers to the response to a request
+func (c CORSConfig) decorate(req *Request, origin string, preflight bool, rsp *Response) {
+	rsp.Header.Add("Vary", "Origin")
+	if preflight {
+		rsp.Header.Add("Vary", "Access-Control-Request-Method")
+		rsp.Header.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if origin == "" || !c.allows(origin) {
+		return
+	}
+
+	if preflight {
+		method := req.Header.Get("Access-Control-Request-Method")
+		allow := rsp.Header.Get("Allow")
+		if allow == "" {
+			allow = method // the handler answered the request itself
+		} else if !containsFold(strings.Split(allow, ","), method) {
+			return
+		}
+		rsp.Header.Set("Access-Control-Allow-Methods", allow)
+		if len(c.Headers) > 0 {
+			rsp.Header.Set("Access-Control-Allow-Headers", strings.Join(c.Headers, ", "))
+		} else if v := req.Header.Get("Access-Control-Request-Headers"); v != "" {
+			rsp.Header.Set("Access-Control-Allow-Headers", v)
+		}
+		if c.MaxAge > 0 {
+			rsp.Header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+		}
+	} else if len(c.Expose) > 0 {
+		rsp.Header.Set("Access-Control-Expose-Headers", strings.Join(c.Expose, ", "))
+	}
+
+	if c.any() && !c.Credentials {
+		rsp.Header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		rsp.Header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.Credentials {
+		rsp.Header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Are all origins allowed
+func (c CORSConfig) any() bool {
+	for _, e := range c.Origins {
+		if e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Is an origin allowed
+func (c CORSConfig) allows(origin string) bool {
+	for _, e := range c.Origins {
+		if e == "*" || strings.EqualFold(e, origin) {
+			return true
+		}
+		if pre, suf, ok := strings.Cut(e, "*"); ok {
+			if len(origin) > len(pre)+len(suf) && strings.HasPrefix(strings.ToLower(origin), strings.ToLower(pre)) && strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suf)) {
+				return true
+			}
+		}
+	}
+	for _, e := range c.Patterns {
+		if e.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Does the list contain a value, ignoring case and surrounding whitespace
+func containsFold(l []string, v string) bool {
+	for _, e := range l {
+		if strings.EqualFold(strings.TrimSpace(e), v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	handler := func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetHeader("X-Total", "1").SetString("text/plain", "OK")
+	}
+
+	r := New(WithAutoOptions(true))
+	r.Use(CORS(
+		WithCORSOrigins("https://app.example.com", "https://*.preview.example.com"),
+		WithCORSOriginPatterns(`https://[a-z]+\.example\.org`),
+		WithCORSExposeHeaders("X-Total"),
+		WithCORSMaxAge(time.Hour),
+	))
+	r.Add("/items/{id}", handler).Methods("GET", "PUT")
+	r.Add("/public", handler).Methods("GET").With(CORSPolicy(WithCORSOrigins("*")))
+	r.Add("/private", handler).Methods("GET").With(CORSPolicy(WithCORSOrigins("https://app.example.com"), WithCORSCredentials(true)))
+	r.Add("/invalid", func(*Request, Context) (*Response, error) {
+		return nil, NewError(http.StatusBadRequest, "Invalid")
+	}).Methods("POST")
+
+	request := func(method, path, origin string, hdrs ...string) *Response {
+		req, err := NewRequest(method, path, nil)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for i := 0; i+1 < len(hdrs); i += 2 {
+			req.Header.Set(hdrs[i], hdrs[i+1])
+		}
+		rsp, err := r.Handle(req)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		return rsp
+	}
+
+	rsp := request("OPTIONS", "/items/1", "https://app.example.com", "Access-Control-Request-Method", "PUT", "Access-Control-Request-Headers", "X-Token")
+	if assert.NotNil(t, rsp) {
+		assert.Equal(t, http.StatusNoContent, rsp.Status)
+		assert.Equal(t, "https://app.example.com", rsp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, OPTIONS, PUT", rsp.Header.Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "X-Token", rsp.Header.Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "3600", rsp.Header.Get("Access-Control-Max-Age"))
+		assert.Contains(t, rsp.Header.Values("Vary"), "Origin")
+	}
+
+	rsp = request("OPTIONS", "/items/1", "https://app.example.com", "Access-Control-Request-Method", "DELETE")
+	if assert.NotNil(t, rsp) {
+		assert.Equal(t, "", rsp.Header.Get("Access-Control-Allow-Origin")) // not a registered method
+	}
+
+	for _, origin := range []string{"https://app.example.com", "https://a.preview.example.com", "https://abc.example.org"} {
+		rsp = request("GET", "/items/1", origin)
+		if assert.NotNil(t, rsp) {
+			assert.Equal(t, origin, rsp.Header.Get("Access-Control-Allow-Origin"), origin)
+			assert.Equal(t, "X-Total", rsp.Header.Get("Access-Control-Expose-Headers"), origin)
+		}
+	}
+	for _, origin := range []string{"https://evil.com", "https://.preview.example.com", "https://abc.example.org.evil.com"} {
+		rsp = request("GET", "/items/1", origin)
+		if assert.NotNil(t, rsp) {
+			assert.Equal(t, http.StatusOK, rsp.Status)
+			assert.Equal(t, "", rsp.Header.Get("Access-Control-Allow-Origin"), origin)
+		}
+	}
+
+	rsp = request("GET", "/public", "https://evil.com")
+	if assert.NotNil(t, rsp) {
+		assert.Equal(t, "*", rsp.Header.Get("Access-Control-Allow-Origin"))
+	}
+	rsp = request("GET", "/private", "https://app.example.com")
+	if assert.NotNil(t, rsp) {
+		assert.Equal(t, "https://app.example.com", rsp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rsp.Header.Get("Access-Control-Allow-Credentials"))
+	}
+	rsp = request("GET", "/items/1", "")
+	if assert.NotNil(t, rsp) {
+		assert.Equal(t, "", rsp.Header.Get("Access-Control-Allow-Origin"))
+	}
+
+	req := httptest.NewRequest("POST", "/invalid", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin")) // errors can be read by the client
+}
+
+func TestCORSErrorHandler(t *testing.T) {
+	r := New(WithErrorHandler(func(req *Request, err error) *Response {
+		rsp, _ := NewResponse(http.StatusServiceUnavailable).SetString("text/plain", "Unavailable")
+		return rsp
+	}))
+	r.Use(CORS(WithCORSOrigins("https://app.example.com")))
+	r.Add("/invalid", func(*Request, Context) (*Response, error) {
+		return nil, NewError(http.StatusBadRequest, "Invalid")
+	}).Methods("POST")
+
+	req, err := NewRequest("POST", "/invalid", nil)
+	if assert.NoError(t, err) {
+		req.Header.Set("Origin", "https://app.example.com")
+		_, err = r.Handle(req)
+		assert.ErrorIs(t, err, NewError(http.StatusBadRequest, "")) // errors are passed on to outer middleware
+	}
+
+	hreq := httptest.NewRequest("POST", "/invalid", nil)
+	hreq.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, hreq)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "Unavailable", rec.Body.String())
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,177 @@
+package router
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decoders for request entities by content encoding. Decoders are provided
+// the maximum decoded size, or a negative value if there is no limit, so that
+// they can bound the memory they allocate.
+var decompressors = map[string]func(io.Reader, int64) (io.ReadCloser, error){
+	EncodingGzip: func(r io.Reader, _ int64) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"x-gzip": func(r io.Reader, _ int64) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	EncodingDeflate: func(r io.Reader, _ int64) (io.ReadCloser, error) {
+		return zlib.NewReader(r) // 'deflate' is the zlib format; see RFC 9110, 8.4.1.2
+	},
+	EncodingZstd: func(r io.Reader, max int64) (io.ReadCloser, error) {
+		w := zstdWindow(max)
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(w), zstd.WithDecoderMaxMemory(w))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	},
+}
+
+// The largest number of content codings which may be applied to a request
+// entity. Each layer adds a decoder, and there is little reason for a client
+// to apply more than one.
+const maxContentCodings = 2
+
+// The smallest zstd window which is accepted regardless of the maximum
+// decoded size. Encoders commonly declare windows of this size even for small
+// entities, and RFC 8878 recommends that decoders support them.
+const minZstdWindow = 8 << 20
+
+// Determine the largest zstd window which is accepted for a maximum decoded
+// size. The window is allocated when a frame is read, before any data is
+// decoded, so it must be bounded independently of the decoded size.
+func zstdWindow(max int64) uint64 {
+	if max <= 0 || max > zstd.MaxWindowSize {
+		return zstd.MaxWindowSize
+	} else if max < minZstdWindow {
+		return minZstdWindow
+	}
+	return uint64(max)
+}
+
+// A decompression option
+type DecompressOption func(DecompressConfig) DecompressConfig
+
+// Decompression middleware configuration
+type DecompressConfig struct {
+	// The maximum size of a decompressed entity. If zero, the default entity
+	// size, DefaultMaxEntityBytes, is used; a negative size disables the limit.
+	MaxBytes int64
+}
+
+// Set the maximum size of a decompressed entity
+func WithDecompressLimit(n int64) DecompressOption {
+	return func(c DecompressConfig) DecompressConfig {
+		c.MaxBytes = n
+		return c
+	}
+}
+
+// Decompress produces middleware which decodes request entities that have a
+// Content-Encoding of gzip, deflate or zstd, or several of those applied in
+// sequence. Handlers receive the decoded entity, without a Content-Encoding
+// header.
+//
+// Reading more than the maximum size from a decoded entity fails with an
+// *http.MaxBytesError, which Request.Decode reports as a 413 error. The zstd
+// window a request may declare is bounded by the same size, with a floor of
+// 8MB, so that the memory allocated to decode it is bounded as well. Requests
+// with an encoding which is not supported, or with more than two encodings
+// applied, produce a 415 response listing the supported encodings.
+func Decompress(opts ...DecompressOption) Middle {
+	var conf DecompressConfig
+	for _, opt := range opts {
+		conf = opt(conf)
+	}
+	if conf.MaxBytes == 0 {
+		conf.MaxBytes = DefaultMaxEntityBytes
+	}
+	return decompress{conf}
+}
+
+type decompress struct {
+	conf DecompressConfig
+}
+
+func (d decompress) String() string {
+	return "Decompress"
+}
+
+func (d decompress) Wrap(h Handler) Handler {
+	return func(req *Request, cxt Context) (*Response, error) {
+		var encs []string
+		for _, e := range strings.Split(strings.Join(req.Header.Values("Content-Encoding"), ","), ",") {
+			if e = strings.ToLower(strings.TrimSpace(e)); e != "" && e != "identity" {
+				encs = append(encs, e)
+			}
+		}
+		if len(encs) == 0 || req.Body == nil || req.Body == http.NoBody {
+			return h(req, cxt)
+		}
+		if len(encs) > maxContentCodings {
+			return unsupportedEncoding(Errorf(http.StatusUnsupportedMediaType, "Too many content encodings: %d", len(encs))), nil
+		}
+		for _, e := range encs {
+			if _, ok := decompressors[e]; !ok {
+				return unsupportedEncoding(Errorf(http.StatusUnsupportedMediaType, "Unsupported content encoding: %s", e)), nil
+			}
+		}
+
+		body := &decodedBody{closers: []io.Closer{req.Body}}
+		var r io.Reader = req.Body
+		for i := len(encs) - 1; i >= 0; i-- { // encodings are listed in the order they were applied
+			dec, err := decompressors[encs[i]](r, d.conf.MaxBytes)
+			if err != nil {
+				body.Close()
+				return nil, NewError(http.StatusBadRequest, "Invalid entity encoding").SetCause(fmt.Errorf("%s: %w", encs[i], err))
+			}
+			body.closers = append(body.closers, dec)
+			r = dec
+		}
+		body.Reader = r
+		if d.conf.MaxBytes > 0 {
+			body.Reader = http.MaxBytesReader(nil, io.NopCloser(r), d.conf.MaxBytes)
+		}
+
+		c := (*http.Request)(req).Clone(req.Context())
+		c.Body = body
+		c.ContentLength = -1
+		c.Header.Del("Content-Encoding")
+		c.Header.Del("Content-Length")
+		defer body.Close()
+		return h((*Request)(c), cxt)
+	}
+}
+
+// Produce a response for a request with an entity that cannot be decoded,
+// listing the supported encodings
+func unsupportedEncoding(err *HTTPError) *Response {
+	rsp := err.Response()
+	rsp.Header.Set("Accept-Encoding", strings.Join([]string{EncodingGzip, EncodingDeflate, EncodingZstd}, ", "))
+	return rsp
+}
+
+// A decoded request entity, which closes its decoders and the original
+// entity when it is closed
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if cerr := b.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	b.closers = nil
+	return err
+}
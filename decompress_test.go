@@ -0,0 +1,123 @@
+package router
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecompress(t *testing.T) {
+	encoders := map[string]func(io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		"deflate": func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		},
+		"zstd": func(w io.Writer) io.WriteCloser {
+			e, _ := zstd.NewWriter(w)
+			return e
+		},
+	}
+	encode := func(data string, encs ...string) []byte {
+		for _, e := range encs {
+			buf := &bytes.Buffer{}
+			w := encoders[e](buf)
+			w.Write([]byte(data))
+			w.Close()
+			data = buf.String()
+		}
+		return []byte(data)
+	}
+
+	r := New()
+	r.Use(Decompress(WithDecompressLimit(1024)))
+	r.Add("/upload", func(req *Request, cxt Context) (*Response, error) {
+		var v struct {
+			Message string `json:"message"`
+		}
+		err := req.Decode(&v)
+		if err != nil {
+			return nil, err
+		}
+		return NewResponse(http.StatusOK).SetHeader("X-Encoding", req.Header.Get("Content-Encoding")).SetString("text/plain", v.Message)
+	}).Methods("POST")
+
+	bomb := `{"message":"` + strings.Repeat("A", 64*1024) + `"}`
+	tests := []struct {
+		Encoding string
+		Entity   []byte
+		Status   int
+		Expect   string
+	}{
+		{"", []byte(`{"message":"Plain"}`), http.StatusOK, "Plain"},
+		{"identity", []byte(`{"message":"Identity"}`), http.StatusOK, "Identity"},
+		{"gzip", encode(`{"message":"Gzip"}`, "gzip"), http.StatusOK, "Gzip"},
+		{"deflate", encode(`{"message":"Deflate"}`, "deflate"), http.StatusOK, "Deflate"},
+		{"zstd", encode(`{"message":"Zstd"}`, "zstd"), http.StatusOK, "Zstd"},
+		{"gzip, zstd", encode(`{"message":"Both"}`, "gzip", "zstd"), http.StatusOK, "Both"},
+		{"gzip", encode(bomb, "gzip"), http.StatusRequestEntityTooLarge, ""},
+		{"zstd", encode(bomb, "zstd"), http.StatusRequestEntityTooLarge, ""},
+		{"gzip", []byte(`{"message":"Not compressed"}`), http.StatusBadRequest, ""},
+		{"br", []byte(`whatever`), http.StatusUnsupportedMediaType, ""},
+		{"gzip, gzip, gzip", encode(`{"message":"Nested"}`, "gzip", "gzip", "gzip"), http.StatusUnsupportedMediaType, ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/upload", bytes.NewReader(e.Entity))
+		req.Header.Set("Content-Type", "application/json")
+		if e.Encoding != "" {
+			req.Header.Set("Content-Encoding", e.Encoding)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if assert.Equal(t, e.Status, rec.Code, e.Encoding) && e.Status == http.StatusOK {
+			assert.Equal(t, e.Expect, rec.Body.String(), e.Encoding)
+			if e.Encoding != "identity" {
+				assert.Equal(t, "", rec.Header().Get("X-Encoding"), e.Encoding)
+			}
+		}
+		if e.Status == http.StatusUnsupportedMediaType {
+			assert.Equal(t, "gzip, deflate, zstd", rec.Header().Get("Accept-Encoding"))
+		}
+	}
+}
+
+func TestDecompressZstdWindow(t *testing.T) {
+	r := New()
+	r.Use(Decompress(WithDecompressLimit(1024)))
+	r.Add("/upload", func(req *Request, cxt Context) (*Response, error) {
+		_, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, NewError(http.StatusBadRequest, "Invalid entity").SetCause(err)
+		}
+		return NewResponse(http.StatusOK), nil
+	}).Methods("POST")
+
+	// an empty frame which declares a 512MB window
+	frame := []byte{
+		0x28, 0xb5, 0x2f, 0xfd, // magic number
+		0x00,             // frame header descriptor: no content size, checksum or dictionary
+		19 << 3,          // window descriptor: 1 << (10 + 19) bytes
+		0x01, 0x00, 0x00, // last block, raw, empty
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	req := httptest.NewRequest("POST", "/upload", bytes.NewReader(frame))
+	req.Header.Set("Content-Encoding", "zstd")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	runtime.ReadMemStats(&after)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(64<<20))
+}
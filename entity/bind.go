@@ -0,0 +1,158 @@
+package entity
+
+import (
+	"encoding"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// Bind assigns values to the fields of the struct pointed to by v which are
+// tagged with the provided tag. The value for each field is obtained from the
+// lookup function using the name in its tag; fields for which no value is
+// found are left unchanged. Fields of embedded structs are also bound.
+//
+// Fields may be strings, booleans, numbers, time.Duration, or any type that
+// implements encoding.TextUnmarshaler, as well as pointers to and slices of
+// those types. Only the first value is used for fields which are not slices.
+func Bind(v interface{}, tag string, lookup func(string) ([]string, bool)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot bind to %T: a non-nil pointer to a struct is required", v)
+	}
+	return bindStruct(rv.Elem(), tag, func(f reflect.Value, name string) error {
+		vals, ok := lookup(name)
+		if !ok || len(vals) == 0 {
+			return nil
+		}
+		if err := setField(f, vals); err != nil {
+			return fmt.Errorf("Invalid value for %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// TagNames returns the names used by fields tagged with the provided tag in
+// the struct type pointed to by v, including those of embedded structs
+func TagNames(v interface{}, tag string) map[string]struct{} {
+	names := make(map[string]struct{})
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return names
+	}
+	bindStruct(rv.Elem(), tag, func(_ reflect.Value, name string) error {
+		names[name] = struct{}{}
+		return nil
+	})
+	return names
+}
+
+// Visit every tagged, settable field in a struct
+func bindStruct(rv reflect.Value, tag string, f func(reflect.Value, string) error) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindStruct(fv, tag, f); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if err := f(fv, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set a field from a set of values
+func setField(f reflect.Value, vals []string) error {
+	if f.Kind() == reflect.Slice && f.Type().Elem().Kind() != reflect.Uint8 && !implementsText(f) {
+		s := reflect.MakeSlice(f.Type(), len(vals), len(vals))
+		for i, e := range vals {
+			if err := setValue(s.Index(i), e); err != nil {
+				return err
+			}
+		}
+		f.Set(s)
+		return nil
+	}
+	return setValue(f, vals[0])
+}
+
+// Set a single value
+func setValue(f reflect.Value, v string) error {
+	if f.Kind() == reflect.Pointer {
+		p := reflect.New(f.Type().Elem())
+		if err := setValue(p.Elem(), v); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+	if implementsText(f) {
+		return f.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(v))
+	}
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(v)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(v, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(v, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(v, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case reflect.Slice: // []byte
+		f.SetBytes([]byte(v))
+	default:
+		return fmt.Errorf("Unsupported field type: %v", f.Type())
+	}
+	return nil
+}
+
+func implementsText(f reflect.Value) bool {
+	return f.CanAddr() && f.Addr().Type().Implements(textUnmarshalerType)
+}
@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBind(t *testing.T) {
+	type embedded struct {
+		Limit int `query:"limit"`
+	}
+	type value struct {
+		embedded
+		Name    string        `query:"name"`
+		Enabled bool          `query:"enabled"`
+		Ratio   float64       `query:"ratio"`
+		Count   *uint         `query:"count"`
+		Timeout time.Duration `query:"timeout"`
+		Addr    net.IP        `query:"addr"`
+		Tags    []string      `query:"tag"`
+		Skip    string        `query:"-"`
+		Other   string
+	}
+
+	vals := map[string][]string{
+		"limit":   {"10"},
+		"name":    {"A", "B"},
+		"enabled": {"true"},
+		"ratio":   {"0.5"},
+		"count":   {"3"},
+		"timeout": {"5s"},
+		"addr":    {"10.0.0.1"},
+		"tag":     {"x", "y"},
+		"-":       {"nope"},
+		"Other":   {"nope"},
+	}
+	lookup := func(k string) ([]string, bool) {
+		v, ok := vals[k]
+		return v, ok
+	}
+
+	var v value
+	if assert.NoError(t, Bind(&v, "query", lookup)) {
+		count := uint(3)
+		assert.Equal(t, value{
+			embedded: embedded{Limit: 10},
+			Name:     "A",
+			Enabled:  true,
+			Ratio:    0.5,
+			Count:    &count,
+			Timeout:  5 * time.Second,
+			Addr:     net.ParseIP("10.0.0.1"),
+			Tags:     []string{"x", "y"},
+		}, v)
+	}
+
+	vals["limit"] = []string{"nope"}
+	assert.Error(t, Bind(&v, "query", lookup))
+	assert.Error(t, Bind(v, "query", lookup))
+
+	assert.Equal(t, map[string]struct{}{
+		"limit": {}, "name": {}, "enabled": {}, "ratio": {}, "count": {}, "timeout": {}, "addr": {}, "tag": {},
+	}, TagNames(&v, "query"))
+}
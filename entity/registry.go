@@ -0,0 +1,232 @@
+package entity
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// An encoder marshals a value to the representation of a media type
+type Encoder func(interface{}) ([]byte, error)
+
+type encoderEntry struct {
+	t string
+	e Encoder
+}
+
+var (
+	encodersLock sync.RWMutex
+	encoders     = []encoderEntry{
+		{"application/json", json.Marshal},
+		{"application/xml", xml.Marshal},
+	}
+)
+
+// RegisterEncoder registers an encoder for a media type. If an encoder is
+// already registered for the type, it is replaced. When values are encoded
+// via content negotiation and a client accepts several types equally, types
+// which were registered earlier are preferred. JSON and XML encoders are
+// registered by default, in that order.
+func RegisterEncoder(t string, e Encoder) {
+	encodersLock.Lock()
+	defer encodersLock.Unlock()
+	for i, x := range encoders {
+		if x.t == t {
+			encoders[i].e = e
+			return
+		}
+	}
+	encoders = append(encoders, encoderEntry{t, e})
+}
+
+// EncoderTypes returns the media types for which encoders are registered, in
+// order of preference
+func EncoderTypes() []string {
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+	types := make([]string, len(encoders))
+	for i, e := range encoders {
+		types[i] = e.t
+	}
+	return types
+}
+
+// LookupEncoder returns the encoder registered for a media type, if any
+func LookupEncoder(t string) (Encoder, bool) {
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+	for _, e := range encoders {
+		if e.t == t {
+			return e.e, true
+		}
+	}
+	return nil, false
+}
+
+// NewValue creates an entity by encoding a value using the encoder registered
+// for the provided media type
+func NewValue(t string, v interface{}) (*readerEntity, error) {
+	enc, ok := LookupEncoder(t)
+	if !ok {
+		return nil, fmt.Errorf("No encoder for media type: %s", t)
+	}
+	d, err := enc(v)
+	if err != nil {
+		return nil, err
+	}
+	return NewBytes(t, d)
+}
+
+// Options which control how entities are decoded
+type DecodeOptions struct {
+	// The maximum size of an entity, in bytes. Decoders which buffer entities
+	// in memory, like the multipart decoder, use this as their limit.
+	MaxBytes int64
+	// Fail when an entity contains fields which do not correspond to a field
+	// in the value it is being decoded into.
+	DisallowUnknownFields bool
+}
+
+// A decoder unmarshals the representation of a media type into a value. The
+// parameters of the media type, such as a multipart boundary, are provided.
+type Decoder func(r io.Reader, params map[string]string, v interface{}, opts DecodeOptions) error
+
+var (
+	decodersLock sync.RWMutex
+	decoders     = map[string]Decoder{
+		"application/json":                  decodeJSON,
+		"application/xml":                   decodeXML,
+		"application/x-www-form-urlencoded": decodeForm,
+		"multipart/form-data":               decodeMultipart,
+	}
+)
+
+// RegisterDecoder registers a decoder for a media type, replacing any decoder
+// that is already registered for it. Decoders for JSON, XML, URL-encoded
+// forms, and multipart forms are registered by default.
+func RegisterDecoder(t string, d Decoder) {
+	decodersLock.Lock()
+	defer decodersLock.Unlock()
+	decoders[strings.ToLower(t)] = d
+}
+
+// LookupDecoder returns the decoder registered for a media type, if any
+func LookupDecoder(t string) (Decoder, bool) {
+	decodersLock.RLock()
+	defer decodersLock.RUnlock()
+	d, ok := decoders[strings.ToLower(t)]
+	return d, ok
+}
+
+func decodeJSON(r io.Reader, _ map[string]string, v interface{}, opts DecodeOptions) error {
+	dec := json.NewDecoder(r)
+	if opts.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+func decodeXML(r io.Reader, _ map[string]string, v interface{}, _ DecodeOptions) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// Decode a URL-encoded form into fields tagged with 'form'
+func decodeForm(r io.Reader, _ map[string]string, v interface{}, opts DecodeOptions) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	vals, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	return bindForm(v, vals, nil, opts)
+}
+
+// Decode a multipart form into fields tagged with 'form'. Files may be bound
+// to fields of type *multipart.FileHeader or []*multipart.FileHeader.
+//
+// Files are kept in memory, rather than written to temporary files, since
+// nothing would remove those files once the value has been used. Memory is
+// bounded by the maximum entity size, and entities with files which exceed it
+// are rejected; when the limit is disabled, files of any size are buffered.
+func decodeMultipart(r io.Reader, params map[string]string, v interface{}, opts DecodeOptions) error {
+	b, ok := params["boundary"]
+	if !ok {
+		return errors.New("No multipart boundary")
+	}
+	mem := opts.MaxBytes
+	if mem <= 0 {
+		mem = math.MaxInt64
+	}
+	form, err := multipart.NewReader(r, b).ReadForm(mem)
+	if err != nil {
+		return err
+	}
+	defer form.RemoveAll()
+	for _, fh := range form.File {
+		for _, e := range fh {
+			if f, err := e.Open(); err != nil {
+				return err
+			} else if _, disk := f.(*os.File); disk {
+				f.Close()
+				return fmt.Errorf("Multipart entity is larger than %d bytes", opts.MaxBytes)
+			} else {
+				f.Close()
+			}
+		}
+	}
+	return bindForm(v, form.Value, form.File, opts)
+}
+
+// Bind form values and files to fields tagged with 'form'
+func bindForm(v interface{}, vals map[string][]string, files map[string][]*multipart.FileHeader, opts DecodeOptions) error {
+	if opts.DisallowUnknownFields {
+		names := TagNames(v, formTag)
+		for k := range vals {
+			if _, ok := names[k]; !ok {
+				return fmt.Errorf("Unknown field: %s", k)
+			}
+		}
+		for k := range files {
+			if _, ok := names[k]; !ok {
+				return fmt.Errorf("Unknown field: %s", k)
+			}
+		}
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot bind to %T: a non-nil pointer to a struct is required", v)
+	}
+	return bindStruct(rv.Elem(), formTag, func(f reflect.Value, name string) error {
+		switch f.Type() {
+		case fileHeaderType:
+			if fh := files[name]; len(fh) > 0 {
+				f.Set(reflect.ValueOf(fh[0]))
+			}
+			return nil
+		case reflect.SliceOf(fileHeaderType):
+			if fh := files[name]; len(fh) > 0 {
+				f.Set(reflect.ValueOf(fh))
+			}
+			return nil
+		}
+		if e := vals[name]; len(e) > 0 {
+			if err := setField(f, e); err != nil {
+				return fmt.Errorf("Invalid value for %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+const formTag = "form"
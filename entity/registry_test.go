@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeMultipart(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	data := strings.Repeat("Upload. ", 1024)
+	entity := &bytes.Buffer{}
+	mw := multipart.NewWriter(entity)
+	mw.WriteField("name", "Example")
+	fw, _ := mw.CreateFormFile("upload", "upload.txt")
+	fw.Write([]byte(data))
+	mw.Close()
+
+	type value struct {
+		Name   string                `form:"name"`
+		Upload *multipart.FileHeader `form:"upload"`
+	}
+	dec, ok := LookupDecoder("multipart/form-data")
+	if !assert.True(t, ok) {
+		return
+	}
+	params := map[string]string{"boundary": mw.Boundary()}
+
+	var v value
+	err := dec(bytes.NewReader(entity.Bytes()), params, &v, DecodeOptions{MaxBytes: -1})
+	if assert.NoError(t, err) && assert.NotNil(t, v.Upload) {
+		assert.Equal(t, "Example", v.Name)
+		f, err := v.Upload.Open()
+		if assert.NoError(t, err) {
+			b, err := io.ReadAll(f)
+			f.Close()
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(b))
+		}
+	}
+
+	v = value{}
+	err = dec(bytes.NewReader(entity.Bytes()), params, &v, DecodeOptions{MaxBytes: 1024})
+	assert.ErrorContains(t, err, "larger than 1024 bytes")
+	assert.Nil(t, v.Upload)
+
+	files, err := os.ReadDir(tmp)
+	if assert.NoError(t, err) {
+		assert.Len(t, files, 0) // no temporary files are left behind
+	}
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotAcceptable matches errors which produce a 406 response; see
+// HTTPError.Is. Functions which report such errors return a new error rather
+// than this one, so it is never shared between requests.
+var ErrNotAcceptable = errNotAcceptable()
+
+func errNotAcceptable() *HTTPError {
+	return NewError(http.StatusNotAcceptable, "Not acceptable")
+}
+
+// An error which describes the response that should be produced for it. The
+// status and message are public and are included in the response; the cause
+// is internal and is never exposed to the client. Fields are additional
+// public members which describe the error.
+//
+// An HTTPError is a Responder which produces an RFC 9457 problem response.
+// When it is returned by a handler served by a router it is rendered by the
+// router's error handler instead; see NewErrorHandler.
+type HTTPError struct {
+	Status  int
+	Message string
+	Cause   error
+	Fields  map[string]interface{}
+}
+
+// Create an error with the provided status and public message
+func NewError(status int, msg string) *HTTPError {
+	return &HTTPError{Status: status, Message: msg}
+}
+
+// Create an error with the provided status and a formatted public message
+func Errorf(status int, f string, a ...interface{}) *HTTPError {
+	return NewError(status, fmt.Sprintf(f, a...))
+}
+
+// Set the internal cause of the error
+func (e *HTTPError) SetCause(err error) *HTTPError {
+	e.Cause = err
+	return e
+}
+
+// Set an additional field which describes the error
+func (e *HTTPError) SetField(k string, v interface{}) *HTTPError {
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{})
+	}
+	e.Fields[k] = v
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %v", msg, e.Cause)
+	}
+	return msg
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// Is the target an HTTPError with the same status. This allows errors to be
+// compared by status with errors.Is.
+func (e *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	return ok && t.Status == e.Status
+}
+
+func (e *HTTPError) Response() *Response {
+	return RenderProblem(nil, e)
+}
+
+// An error renderer produces the response which describes an error
+type ErrorRenderer func(*Request, *HTTPError) *Response
+
+// RenderProblem renders an error as an RFC 9457 'application/problem+json'
+// response. The status and message are rendered as the problem's status, title
+// and detail; fields are rendered as extension members, except where they
+// conflict with the standard members. The cause is never rendered.
+func RenderProblem(req *Request, e *HTTPError) *Response {
+	status := e.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	problem := make(map[string]interface{})
+	for k, v := range e.Fields {
+		problem[k] = v
+	}
+	problem["type"] = "about:blank"
+	problem["title"] = http.StatusText(status)
+	problem["status"] = status
+	if e.Message != "" {
+		problem["detail"] = e.Message
+	} else {
+		delete(problem, "detail")
+	}
+	data, err := json.Marshal(problem)
+	if err != nil { // a field couldn't be marshaled; render the standard members alone
+		data, _ = json.Marshal(map[string]interface{}{
+			"type":   "about:blank",
+			"title":  http.StatusText(status),
+			"status": status,
+			"detail": e.Message,
+		})
+	}
+	rsp, _ := NewResponse(status).SetBytes("application/problem+json", data)
+	return rsp
+}
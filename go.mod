@@ -6,6 +6,7 @@ toolchain go1.22.5
 
 require (
 	github.com/bww/go-util v1.38.0
+	github.com/klauspost/compress v1.18.0
 	github.com/stretchr/testify v1.9.0
 )
 
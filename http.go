@@ -0,0 +1,165 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// An error handler converts an error produced while handling a request into a
+// response that can be written to the client.
+type ErrorHandler func(*Request, error) *Response
+
+// DefaultErrorHandler produces a response for an error by rendering it as an
+// RFC 9457 problem. See NewErrorHandler.
+var DefaultErrorHandler = NewErrorHandler(RenderProblem)
+
+// NewErrorHandler creates an error handler which renders errors using the
+// provided renderer. If the error is an *HTTPError (or wraps one) it is
+// rendered directly. Otherwise, if the error is a Responder (or wraps one) its
+// response is used. Any other error is logged and rendered as a generic 500
+// error which does not expose it. HTTPErrors with a 5XX status and a cause
+// are also logged.
+func NewErrorHandler(render ErrorRenderer) ErrorHandler {
+	return func(req *Request, err error) *Response {
+		var e *HTTPError
+		if errors.As(err, &e) {
+			if e.Status >= 500 && e.Cause != nil {
+				slog.With("method", req.Method, "path", req.URL.Path, "error", err).Error("Could not handle request")
+			}
+			return render(req, e)
+		}
+		var r Responder
+		if errors.As(err, &r) {
+			if rsp := r.Response(); rsp != nil {
+				return rsp
+			}
+		}
+		slog.With("method", req.Method, "path", req.URL.Path, "error", err).Error("Could not handle request")
+		return render(req, NewError(http.StatusInternalServerError, "Internal server error"))
+	}
+}
+
+// Functions which are invoked with the response the error handler produces
+// for a request, if handling it fails
+type errorHooks struct {
+	fns []func(*Response)
+}
+
+type errorHooksKey struct{}
+
+// Register a function which is invoked with the response the error handler
+// produces if handling a request fails, before the response is written.
+// Functions are invoked in the order they are registered, which, when they
+// are registered by middleware as an error is returned, is innermost first.
+// If the request is not being served via ServeHTTP, nothing will render its
+// errors and false is returned.
+func onErrorResponse(req *Request, f func(*Response)) bool {
+	h, ok := req.Context().Value(errorHooksKey{}).(*errorHooks)
+	if ok {
+		h.fns = append(h.fns, f)
+	}
+	return ok
+}
+
+// ServeHTTP adapts the router to net/http. The request is handled by the
+// router and the resulting response is written to the client. Errors returned
+// by handlers are converted into responses by the configured error handler.
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	serve(w, (*Request)(req), r.Handle, r.config.ErrorHandler)
+}
+
+func serve(w http.ResponseWriter, req *Request, h func(*Request) (*Response, error), eh ErrorHandler) {
+	hooks := &errorHooks{}
+	req = (*Request)((*http.Request)(req).WithContext(context.WithValue(req.Context(), errorHooksKey{}, hooks)))
+	rsp, err := h(req)
+	if err != nil {
+		if rsp != nil && rsp.Entity != nil {
+			rsp.Entity.Close() // we're discarding this response
+		}
+		if eh == nil {
+			eh = DefaultErrorHandler
+		}
+		rsp = eh(req, err)
+		if rsp == nil {
+			rsp = NewResponse(http.StatusNoContent)
+		}
+		if rsp.Header == nil {
+			rsp.Header = make(http.Header)
+		}
+		for _, f := range hooks.fns {
+			f(rsp)
+		}
+	}
+	if rsp == nil {
+		rsp = NewResponse(http.StatusNoContent)
+	}
+	err = writeResponse(w, rsp)
+	if err != nil {
+		slog.With("method", req.Method, "path", req.URL.Path, "error", err).Warn("Could not write response")
+	}
+}
+
+// Write a response to the provided writer. The response entity, if there is
+// one, is always closed. Streaming responses are flushed to the client as
+// entity data becomes available.
+func writeResponse(w http.ResponseWriter, rsp *Response) error {
+	if rsp.Entity != nil {
+		defer rsp.Entity.Close()
+	}
+
+	hdr := w.Header()
+	for k, v := range rsp.Header {
+		hdr[k] = v
+	}
+	status := rsp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	if rsp.Entity == nil {
+		return nil
+	}
+	if rsp.Streaming {
+		return copyFlush(w, rsp.Entity)
+	}
+	_, err := io.Copy(w, rsp.Entity)
+	return err
+}
+
+// Copy data from the reader to the writer, flushing after each write. If the
+// writer does not support flushing the data is simply copied.
+func copyFlush(w http.ResponseWriter, r io.Reader) error {
+	ctl := http.NewResponseController(w)
+	flush := func() error {
+		err := ctl.Flush()
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+	err := flush() // send headers immediately
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if rerr == io.EOF {
+			return nil
+		} else if rerr != nil {
+			return rerr
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestServeHTTP(t *testing.T) {
+	var entity *closeTracker
+
+	r := New()
+	r.Add("/a", func(*Request, Context) (*Response, error) {
+		entity = &closeTracker{Reader: strings.NewReader("Hello")}
+		rsp := NewResponse(http.StatusCreated).SetHeader("X-Test", "A")
+		rsp.Entity = entity
+		return rsp, nil
+	}).Methods("GET")
+	r.Add("/b", func(*Request, Context) (*Response, error) {
+		return nil, responderError{NewResponse(http.StatusTeapot)}
+	}).Methods("GET")
+	r.Add("/c", func(*Request, Context) (*Response, error) {
+		return nil, errors.New("Secret internal details")
+	}).Methods("GET")
+	r.Add("/d", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetStreaming(true).SetString("text/plain", "Streamed")
+	}).Methods("GET")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/a", nil))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "A", rec.Header().Get("X-Test"))
+	assert.Equal(t, "Hello", rec.Body.String())
+	if assert.NotNil(t, entity) {
+		assert.True(t, entity.closed)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/b", nil))
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/c", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.NotContains(t, rec.Body.String(), "Secret")
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/d", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "Streamed", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/x", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServeHTTPErrorHandler(t *testing.T) {
+	r := New(WithErrorHandler(func(req *Request, err error) *Response {
+		rsp, _ := NewResponse(http.StatusBadGateway).SetString("text/plain", err.Error())
+		return rsp
+	}))
+	r.Add("/a", func(*Request, Context) (*Response, error) {
+		return nil, errors.New("Upstream failed")
+	})
+
+	rec := httptest.NewRecorder()
+	r.Subrouter("/x").ServeHTTP(rec, httptest.NewRequest("GET", "/a", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, "Upstream failed", rec.Body.String())
+}
+
+func TestServeHTTPProblem(t *testing.T) {
+	r := New()
+	r.Add("/a", func(*Request, Context) (*Response, error) {
+		return nil, NewError(http.StatusConflict, "Already exists").SetCause(errors.New("Secret internal details")).SetField("id", "123")
+	})
+	r.Add("/b", func(*Request, Context) (*Response, error) {
+		return nil, fmt.Errorf("Wrapped: %w", NewError(http.StatusServiceUnavailable, "").SetField("status", 1))
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/a", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Conflict","status":409,"detail":"Already exists","id":"123"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/b", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Service Unavailable","status":503}`, rec.Body.String())
+
+	r = New(WithErrorHandler(NewErrorHandler(func(req *Request, e *HTTPError) *Response {
+		rsp, _ := NewResponse(e.Status).SetString("text/plain", e.Message)
+		return rsp
+	})))
+	r.Add("/a", func(*Request, Context) (*Response, error) {
+		return nil, errors.New("Secret internal details")
+	})
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/a", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "Internal server error", rec.Body.String())
+}
+
+type responderError struct {
+	rsp *Response
+}
+
+func (e responderError) Error() string {
+	return http.StatusText(e.rsp.Status)
+}
+
+func (e responderError) Response() *Response {
+	return e.rsp
+}
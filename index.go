@@ -0,0 +1,79 @@
+package router
+
+import (
+	"log/slog"
+	"sort"
+
+	"github.com/bww/go-router/v2/path"
+)
+
+// A route path which is a candidate for matching a request
+type candidate struct {
+	route *Route
+	path  path.Path
+	order int // the order in which the candidate is evaluated
+}
+
+type candidates []candidate
+
+// A dispatch index which maps path templates to the routes that declare them
+type index struct {
+	tree *path.Tree[*candidates]
+}
+
+// Build an index for the provided routes. Every path of every route is
+// indexed under its template; equivalent templates share a set of candidates.
+// Candidates are evaluated in the specified order.
+func newIndex(routes []*Route, order Order) *index {
+	all := orderCandidates(routes, order)
+	tree := &path.Tree[*candidates]{}
+	for _, e := range all {
+		t := e.path.String()
+		c, ok := tree.Lookup(t)
+		if !ok {
+			c = &candidates{}
+			if err := tree.Add(t, c); err != nil {
+				slog.With("route", e.route.Describe(false), "error", err).Warn("Could not index route")
+				continue
+			}
+		}
+		*c = append(*c, e)
+	}
+	return &index{tree: tree}
+}
+
+// Produce candidates for every path of every route in the order they should
+// be evaluated
+func orderCandidates(routes []*Route, order Order) []candidate {
+	var all []candidate
+	for _, r := range routes {
+		for _, p := range r.pathList() {
+			all = append(all, candidate{route: r, path: p})
+		}
+	}
+	if order == OrderSpecificity {
+		sort.SliceStable(all, func(i, j int) bool {
+			return all[i].path.Compare(all[j].path) < 0
+		})
+	}
+	for i := range all {
+		all[i].order = i
+	}
+	return all
+}
+
+// Find candidates whose templates match the provided path, in the order they
+// should be evaluated. Variables are not captured here, since candidates which
+// share a template may name them differently; the router captures them from
+// the candidate's own path.
+func (x *index) find(p string) []candidate {
+	var res []candidate
+	x.tree.MatchFunc(p, func(c *candidates) bool {
+		res = append(res, (*c)...)
+		return true
+	})
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].order < res[j].order
+	})
+	return res
+}
@@ -0,0 +1,247 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	pathutil "path"
+
+	"github.com/bww/go-router/v2/path"
+)
+
+// Finds routes and explains why a request was not matched
+type finder interface {
+	find(*Request) (*Route, *Match, *miss)
+}
+
+// A router or handler mounted under a path prefix
+type mount struct {
+	prefix  path.Path
+	router  Router
+	handler http.Handler
+}
+
+// The route and match in a mounted router which a match delegates to
+type delegate struct {
+	route *Route
+	match *Match
+}
+
+// Create a route which delegates requests under the provided prefix to a
+// mount. The route's path matches the prefix and everything beneath it.
+func newMountRoute(p string, m *mount) *Route {
+	r := &Route{
+		paths: []path.Path{path.Parse(pathutil.Join(p, "**"))},
+		mount: m,
+	}
+	r.handler = m.handle
+	r.base = r.handler
+	return r
+}
+
+// Delegate a match to the mounted router. The delegated match describes the
+// full path template, including the prefix, and includes vars captured by the
+// prefix as well as the mounted route. If the mounted router does not match
+// the request, a description of why is returned instead.
+func (m *mount) delegate(req *Request, match *Match) (*Match, *miss) {
+	ok, vars, rest := m.prefix.MatchesPrefix(req.URL.Path)
+	if !ok {
+		return nil, nil
+	}
+	if m.router == nil {
+		return match, nil // a handler accepts everything under its prefix
+	}
+
+	var (
+		route *Route
+		inner *Match
+		why   *miss
+	)
+	sub := m.strip(req, rest)
+	if f, ok := m.router.(finder); ok {
+		route, inner, why = f.find(sub)
+	} else {
+		route, inner, _ = m.router.Find(sub)
+	}
+	if route == nil {
+		return nil, why
+	}
+
+	if len(inner.Vars) > 0 {
+		if vars == nil {
+			vars = make(path.Vars)
+		}
+		for k, v := range inner.Vars {
+			vars[k] = v
+		}
+	}
+	return &Match{
+		Route:    route,
+		Method:   req.Method,
+		Path:     pathutil.Join(m.prefix.String(), inner.Path),
+		Params:   inner.Params,
+		Vars:     vars,
+		delegate: &delegate{route: route, match: inner},
+	}, nil
+}
+
+// Handle a request by delegating it to the mounted router or handler with the
+// prefix stripped from its path.
+func (m *mount) handle(req *Request, cxt Context) (*Response, error) {
+	_, _, rest := m.prefix.MatchesPrefix(req.URL.Path)
+	sub := m.strip(req, rest)
+	if m.handler != nil {
+		return serveHandler(m.handler, (*http.Request)(sub)), nil
+	}
+
+	match := MatchFromContext(req.Context())
+	if match == nil || match.delegate == nil {
+		return m.router.Handle(sub) // we weren't matched by our router; start over
+	}
+
+	d := match.delegate
+	attrs := cxt.Attrs.Copy()
+	for k, v := range d.route.attrs {
+		attrs[k] = v
+	}
+	return d.route.Handle(sub, Context{
+		Vars:  cxt.Vars,
+		Attrs: attrs,
+		Path:  cxt.Path,
+	})
+}
+
+// Derive a request with the mount prefix removed from its path
+func (m *mount) strip(req *Request, rest string) *Request {
+	u := *req.URL
+	u.Path = "/" + rest
+	u.RawPath = ""
+	c := *req
+	c.URL = &u
+	return &c
+}
+
+// Produce a URL for a named route in the mounted router, if there is one
+func (m *mount) URL(name string, vars path.Vars, query url.Values) (*url.URL, error) {
+	if m.router == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRouteNotFound, name)
+	}
+	u, err := m.router.URL(name, vars, query)
+	if err != nil {
+		return nil, err
+	}
+	p, err := m.prefix.Expand(vars)
+	if err != nil {
+		return nil, err
+	}
+	v, err := url.Parse(strings.TrimSuffix(p, "/") + u.EscapedPath())
+	if err != nil {
+		return nil, err
+	}
+	v.RawQuery = u.RawQuery
+	return v, nil
+}
+
+// Mount a router under the provided prefix. Requests under the prefix are
+// delegated to the mounted router with the prefix removed from their paths.
+// The mounted router applies its own middleware, which is nested inside the
+// middleware of this router. Matches describe the full path template of the
+// mounted route, including the prefix.
+//
+// If the mounted router has no route for a request, the search for a route
+// continues in this router.
+func (r *router) Mount(p string, m Router) *Route {
+	return r.add(newMountRoute(p, &mount{prefix: path.Parse(p), router: m}))
+}
+
+// Mount an http.Handler under the provided prefix. Requests under the prefix
+// are delegated to the handler with the prefix removed from their paths. The
+// handler's response is streamed to the client.
+func (r *router) MountHandler(p string, h http.Handler) *Route {
+	return r.add(newMountRoute(p, &mount{prefix: path.Parse(p), handler: h}))
+}
+
+// Mount a router under the provided prefix, relative to this subrouter
+func (r *subrouter) Mount(p string, m Router) *Route {
+	p = pathutil.Join(r.prefix, p)
+	v := newMountRoute(p, &mount{prefix: path.Parse(p), router: m})
+	v.scope = r
+	return r.root.add(v)
+}
+
+// Mount an http.Handler under the provided prefix, relative to this subrouter
+func (r *subrouter) MountHandler(p string, h http.Handler) *Route {
+	p = pathutil.Join(r.prefix, p)
+	v := newMountRoute(p, &mount{prefix: path.Parse(p), handler: h})
+	v.scope = r
+	return r.root.add(v)
+}
+
+// Serve a request using an http.Handler. The handler is run concurrently and
+// its output is provided as a streaming response entity, which is available
+// once the handler writes its header or some data, or returns.
+func serveHandler(h http.Handler, req *http.Request) *Response {
+	pr, pw := io.Pipe()
+	w := &pipeWriter{
+		header: make(http.Header),
+		pipe:   pw,
+		ready:  make(chan struct{}),
+	}
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				slog.With("path", req.URL.Path, "panic", v).Error("Mounted handler panicked")
+				w.WriteHeader(http.StatusInternalServerError)
+				pw.CloseWithError(fmt.Errorf("Handler panicked: %v", v))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			pw.Close()
+		}()
+		h.ServeHTTP(w, req)
+	}()
+	<-w.ready
+	return &Response{
+		Status:    w.status,
+		Header:    w.snapshot,
+		Entity:    pr,
+		Streaming: true,
+	}
+}
+
+// An http.ResponseWriter which writes the response entity to a pipe
+type pipeWriter struct {
+	header   http.Header
+	snapshot http.Header
+	status   int
+	pipe     *io.PipeWriter
+	ready    chan struct{}
+	once     sync.Once
+}
+
+func (w *pipeWriter) Header() http.Header {
+	return w.header
+}
+
+// Set the status and make the response available; only the first call has
+// any effect, as with an http.ResponseWriter.
+func (w *pipeWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.snapshot = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.pipe.Write(b)
+}
+
+// Data is delivered to the reader as it is written, so there's nothing to do
+func (w *pipeWriter) Flush() {}
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bww/go-router/v2/path"
+	"github.com/bww/go-util/v1/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMount(t *testing.T) {
+	tag := func(v string) Middle {
+		return MiddleFunc(func(h Handler) Handler {
+			return func(req *Request, cxt Context) (*Response, error) {
+				rsp, err := h(req, cxt)
+				if err != nil {
+					return nil, err
+				}
+				return rsp.SetString("text/plain", v+"("+string(errors.Must(io.ReadAll(rsp.Entity)))+")")
+			}
+		})
+	}
+
+	inner := New()
+	inner.Use(tag("I"))
+	inner.Add("/users/{id}", func(req *Request, cxt Context) (*Response, error) {
+		match := MatchFromContext(req.Context())
+		return NewResponse(http.StatusOK).SetString("text/plain", fmt.Sprintf("%s %s %s %s", req.URL.Path, cxt.Path, match.Path, cxt.Vars["id"]))
+	}).Methods("GET").Name("user")
+
+	r := New()
+	r.Use(tag("R"))
+	r.Mount("/api", inner)
+	r.Add("/api/other", func(req *Request, cxt Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "other")
+	})
+	r.MountHandler("/static", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Static", "true")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, req.URL.Path)
+	}))
+
+	req, err := NewRequest("GET", "/api/users/123", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("R(I(/users/123 /api/users/{id} /api/users/{id} 123))"), nil)
+		_, match, err := r.Find(req)
+		if assert.NoError(t, err) && assert.NotNil(t, match) {
+			assert.Equal(t, "/api/users/{id}", match.Path)
+			assert.Equal(t, path.Vars{"id": "123"}, match.Vars)
+		}
+	}
+	req, err = NewRequest("GET", "/api/other", nil) // not matched by the mounted router
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("R(other)"), nil)
+	}
+	req, err = NewRequest("DELETE", "/api/users/123", nil)
+	if assert.NoError(t, err) {
+		rsp, err := r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusMethodNotAllowed, rsp.Status)
+			assert.Equal(t, "GET", rsp.Header.Get("Allow"))
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/static/a/b.css", nil))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("X-Static"))
+	assert.Equal(t, "R(/a/b.css)", rec.Body.String())
+
+	u, err := r.URL("user", path.Vars{"id": "abc"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/api/users/abc", u.String())
+	}
+}
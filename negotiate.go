@@ -0,0 +1,98 @@
+package router
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A value with a quality, as found in headers like Accept
+type qualified struct {
+	value string
+	q     float64
+}
+
+// Parse a header with quality values, like Accept or Accept-Encoding. Values
+// are returned in order of decreasing quality; values with equal quality
+// retain the order in which they appear. Media type parameters other than the
+// quality are discarded. Malformed quality values are treated as 1.
+func parseQualified(h string) []qualified {
+	var res []qualified
+	for _, e := range strings.Split(h, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		q := 1.0
+		v, params, ok := strings.Cut(e, ";")
+		for ok {
+			var p string
+			p, params, ok = strings.Cut(params, ";")
+			k, x, _ := strings.Cut(p, "=")
+			if strings.EqualFold(strings.TrimSpace(k), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		res = append(res, qualified{value: strings.ToLower(strings.TrimSpace(v)), q: q})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].q > res[j].q
+	})
+	return res
+}
+
+// Determine the quality with which a media type is accepted by the provided
+// media ranges. The most specific range which matches the type determines its
+// quality. If no range matches, the quality is zero.
+func acceptQuality(ranges []qualified, t string) float64 {
+	var (
+		q    float64
+		spec = -1
+	)
+	for _, e := range ranges {
+		if !mediaMatches(e.value, t) {
+			continue
+		}
+		if s := mediaSpecificity(e.value); s > spec {
+			q, spec = e.q, s
+		}
+	}
+	return q
+}
+
+// Does a media type match a media range, which may contain wildcards in the
+// form 'type/*' or '*/*'. Parameters are not considered.
+func mediaMatches(r, t string) bool {
+	r, t = mediaType(r), mediaType(t)
+	if r == "*/*" || r == "*" || r == t {
+		return true
+	}
+	rt, rs, _ := strings.Cut(r, "/")
+	tt, _, _ := strings.Cut(t, "/")
+	return rs == "*" && rt == tt
+}
+
+// The specificity of a media range: '*/*' is the least specific, 'type/*' is
+// more specific, and a full media type is the most specific.
+func mediaSpecificity(r string) int {
+	r = mediaType(r)
+	if r == "*/*" || r == "*" {
+		return 0
+	} else if strings.HasSuffix(r, "/*") {
+		return 1
+	} else {
+		return 2
+	}
+}
+
+// Obtain the media type, without parameters and in lower case
+func mediaType(t string) string {
+	if m, _, err := mime.ParseMediaType(t); err == nil {
+		return m
+	}
+	m, _, _ := strings.Cut(t, ";")
+	return strings.ToLower(strings.TrimSpace(m))
+}
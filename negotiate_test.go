@@ -0,0 +1,44 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQualified(t *testing.T) {
+	tests := []struct {
+		Header string
+		Expect []qualified
+	}{
+		{
+			"", nil,
+		},
+		{
+			"application/json", []qualified{{"application/json", 1}},
+		},
+		{
+			"text/html;level=1, application/json;q=0.5, */*;q=0.1, text/plain",
+			[]qualified{{"text/html", 1}, {"text/plain", 1}, {"application/json", 0.5}, {"*/*", 0.1}},
+		},
+		{
+			"gzip;q=0.8, zstd, identity;q=0", []qualified{{"zstd", 1}, {"gzip", 0.8}, {"identity", 0}},
+		},
+		{
+			"gzip;q=nope", []qualified{{"gzip", 1}},
+		},
+	}
+	for _, e := range tests {
+		assert.Equal(t, e.Expect, parseQualified(e.Header), e.Header)
+	}
+}
+
+func TestAcceptQuality(t *testing.T) {
+	ranges := parseQualified("text/*;q=0.5, text/plain, application/json;q=0, */*;q=0.1")
+	assert.Equal(t, 1.0, acceptQuality(ranges, "text/plain"))
+	assert.Equal(t, 1.0, acceptQuality(ranges, "text/plain; charset=utf-8"))
+	assert.Equal(t, 0.5, acceptQuality(ranges, "text/html"))
+	assert.Equal(t, 0.0, acceptQuality(ranges, "application/json"))
+	assert.Equal(t, 0.1, acceptQuality(ranges, "image/png"))
+	assert.Equal(t, 0.0, acceptQuality(parseQualified("text/plain"), "image/png"))
+}
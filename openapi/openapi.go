@@ -0,0 +1,431 @@
+// Package openapi generates OpenAPI 3.1 documents which describe the routes
+// registered with a router.
+package openapi
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	pathutil "path"
+
+	router "github.com/bww/go-router/v2"
+	"github.com/bww/go-router/v2/path"
+)
+
+const Version = "3.1.0"
+
+// Methods which may be described by a path item
+var methods = map[string]struct{}{
+	"get": {}, "put": {}, "post": {}, "delete": {}, "options": {}, "head": {}, "patch": {}, "trace": {},
+}
+
+// Headers which OpenAPI does not permit to be described as parameters
+var reservedHeaders = map[string]struct{}{
+	"Accept": {}, "Content-Type": {}, "Authorization": {},
+}
+
+// An OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components *Components         `json:"components,omitempty"`
+}
+
+// Metadata which describes an API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operations on a path, by lower case method
+type PathItem map[string]*Operation
+
+// An operation on a path
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// A parameter to an operation
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+// The entity accepted by an operation
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// A response produced by an operation
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// The schema of an entity in a particular media type
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Reusable components referenced by a document
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// A collision between routes with different paths which are described by
+// the same operation, because their paths differ only in the constraints on
+// their variables, which OpenAPI path templates cannot express
+type Collision struct {
+	Method string // the method of the operation, in upper case
+	Path   string // the path template of the operation
+	Used   string // the path of the route which describes the operation
+	Other  string // the path of the route which is not described
+}
+
+func (c Collision) String() string {
+	return fmt.Sprintf("%s %s: %s is described, %s is not", c.Method, c.Path, c.Used, c.Other)
+}
+
+// An error which reports the collisions found while generating a document
+type CollisionError []Collision
+
+func (e CollisionError) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("Colliding operations:")
+	for _, c := range e {
+		b.WriteString("\n  ")
+		b.WriteString(c.String())
+	}
+	return b.String()
+}
+
+// Generate a document which describes the routes of a router. Routes which
+// mount other routers are described by the routes of the mounted router,
+// under the mount prefix.
+//
+// Each route is described as an operation for every method and path it
+// matches. Routes which match any method, routes whose paths contain anonymous
+// wildcards or multi-component variables, and routes marked Hidden are not
+// described. Where more than one route describes the same operation, the
+// first one is used, as it would be by the router.
+//
+// Routes whose paths differ only in the constraints on their variables, like
+// '/x/{id:int}' and '/x/{id:uuid}', cannot both be described. The document is
+// still produced, describing the first of them, and the others are reported
+// as a CollisionError.
+func Generate(r router.Router, info Info) (*Document, error) {
+	g := &generator{
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   make(map[string]PathItem),
+		},
+		schemas: newSchemas(),
+		sources: make(map[string]string),
+	}
+	g.routes("", r.Routes())
+	if len(g.schemas.components) > 0 {
+		g.doc.Components = &Components{Schemas: g.schemas.components}
+	}
+	if len(g.collisions) > 0 {
+		return g.doc, g.collisions
+	}
+	return g.doc, nil
+}
+
+// Produce a handler which serves a document describing the routes of a
+// router as JSON. The document is generated for every request, so it
+// reflects the routes as they are at that time. Collisions are logged and
+// the document is served regardless.
+func Handler(r router.Router, info Info) router.Handler {
+	return func(req *router.Request, cxt router.Context) (*router.Response, error) {
+		doc, err := Generate(r, info)
+		if err != nil {
+			slog.With("error", err).Warn("Some routes cannot be described")
+		}
+		return router.NewResponse(http.StatusOK).SetJSON(doc)
+	}
+}
+
+type generator struct {
+	doc        *Document
+	schemas    *schemas
+	sources    map[string]string // the router path which describes each operation
+	collisions CollisionError
+}
+
+func (g *generator) routes(prefix string, routes []*router.Route) {
+	for _, e := range routes {
+		info := e.Info()
+		if hidden, _ := info.Attrs[AttrHidden].(bool); hidden {
+			continue
+		}
+		if info.Mounted != nil {
+			for _, p := range info.Paths {
+				g.routes(pathutil.Join(prefix, strings.TrimSuffix(p.String(), "/**")), info.Mounted.Routes())
+			}
+			continue
+		}
+		if len(info.Methods) == 0 {
+			continue
+		}
+		for _, p := range info.Paths {
+			if prefix != "" {
+				p = path.Parse(pathutil.Join(prefix, p.String()))
+			}
+			tmpl, params, ok := template(p)
+			if !ok {
+				continue
+			}
+			item, ok := g.doc.Paths[tmpl]
+			if !ok {
+				item = make(PathItem)
+				g.doc.Paths[tmpl] = item
+			}
+			for _, m := range info.Methods {
+				m = strings.ToLower(m)
+				if _, ok := methods[m]; !ok {
+					continue
+				}
+				key := m + " " + tmpl
+				if _, ok := item[m]; ok {
+					if src := g.sources[key]; src != p.String() {
+						g.collisions = append(g.collisions, Collision{Method: strings.ToUpper(m), Path: tmpl, Used: src, Other: p.String()})
+					}
+					continue // an earlier route handles this operation
+				}
+				g.sources[key] = p.String()
+				op := g.operation(info, params)
+				if len(info.Methods) == 1 && len(info.Paths) == 1 {
+					op.OperationID = info.Name // only a single operation can use the route's name
+				}
+				item[m] = op
+			}
+		}
+	}
+}
+
+// Describe the operation performed by a route
+func (g *generator) operation(info router.RouteInfo, params []*Parameter) *Operation {
+	op := &Operation{
+		Responses: make(map[string]*Response),
+	}
+	op.Summary, _ = info.Attrs[AttrSummary].(string)
+	op.Description, _ = info.Attrs[AttrDescription].(string)
+	op.Tags, _ = info.Attrs[AttrTags].([]string)
+	op.Deprecated, _ = info.Attrs[AttrDeprecated].(bool)
+
+	vars := make(map[string]*Parameter)
+	for _, e := range params {
+		c := *e
+		op.Parameters = append(op.Parameters, &c)
+		vars[c.Name] = &c
+	}
+	var extra []*Parameter
+	for k, v := range info.Params {
+		extra = append(extra, &Parameter{Name: k, In: "query", Required: true, Schema: enumSchema(v)})
+	}
+	for k, v := range info.Headers {
+		if _, ok := reservedHeaders[k]; !ok {
+			extra = append(extra, &Parameter{Name: k, In: "header", Required: true, Schema: enumSchema(v)})
+		}
+	}
+
+	if t, ok := info.Attrs[AttrInput].(reflect.Type); ok && t != nil {
+		var body *Schema
+		body, extra = g.input(t, vars, extra)
+		if body != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  content(info.Consumes, body),
+			}
+		}
+	}
+	sort.SliceStable(extra, func(i, j int) bool {
+		if extra[i].In != extra[j].In {
+			return extra[i].In > extra[j].In // query before header
+		}
+		return extra[i].Name < extra[j].Name
+	})
+	op.Parameters = append(op.Parameters, extra...)
+
+	outputs, _ := info.Attrs[AttrOutputs].(map[int]reflect.Type)
+	statuses := make([]int, 0, len(outputs))
+	for k := range outputs {
+		statuses = append(statuses, k)
+	}
+	sort.Ints(statuses) // describe types in a consistent order
+	for _, status := range statuses {
+		t := outputs[status]
+		rsp := &Response{Description: http.StatusText(status)}
+		if t != nil {
+			rsp.Content = content(info.Produces, g.schemas.schema(t))
+		}
+		op.Responses[strconv.Itoa(status)] = rsp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &Response{Description: "Default response"}
+	}
+	return op
+}
+
+// Describe the input of an operation. Fields tagged as path variables refine
+// the description of those parameters and fields tagged as query parameters or
+// headers are added to the extra parameters. The schema of the entity is
+// returned, if there is one.
+func (g *generator) input(t reflect.Type, vars map[string]*Parameter, extra []*Parameter) (*Schema, []*Parameter) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return g.schemas.schema(t), extra
+	}
+
+	var bound bool
+	tagged := func(f reflect.StructField) bool {
+		for _, tag := range []string{"path", "query", "header"} {
+			if n, _, _ := strings.Cut(f.Tag.Get(tag), ","); n != "" && n != "-" {
+				return true
+			}
+		}
+		return false
+	}
+	visitFields(t, func(f reflect.StructField) {
+		if !tagged(f) {
+			return
+		}
+		bound = true
+		schema := g.schemas.schema(f.Type)
+		if n, _, _ := strings.Cut(f.Tag.Get("path"), ","); n != "" && n != "-" {
+			if p, ok := vars[n]; ok {
+				p.Schema = schema
+			}
+		}
+		if n, _, _ := strings.Cut(f.Tag.Get("query"), ","); n != "" && n != "-" {
+			extra = appendParam(extra, &Parameter{Name: n, In: "query", Schema: schema})
+		}
+		if n, _, _ := strings.Cut(f.Tag.Get("header"), ","); n != "" && n != "-" {
+			extra = appendParam(extra, &Parameter{Name: http.CanonicalHeaderKey(n), In: "header", Schema: schema})
+		}
+	})
+	if !bound {
+		return g.schemas.schema(t), extra
+	}
+	body := g.schemas.object(t, tagged)
+	if len(body.Properties) == 0 {
+		return nil, extra
+	}
+	return body, extra
+}
+
+// Visit the exported fields of a struct, including those of embedded structs
+func visitFields(t reflect.Type, f func(reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			visitFields(field.Type, f)
+		} else if field.IsExported() {
+			f(field)
+		}
+	}
+}
+
+// Add a parameter unless one with the same name and location already exists
+func appendParam(params []*Parameter, p *Parameter) []*Parameter {
+	for _, e := range params {
+		if e.Name == p.Name && e.In == p.In {
+			return params
+		}
+	}
+	return append(params, p)
+}
+
+// Convert a path to an OpenAPI path template and describe its variables as
+// parameters. Paths which contain anonymous wildcards or multi-component
+// variables cannot be described, since path parameters cannot contain '/'.
+func template(p path.Path) (string, []*Parameter, bool) {
+	var params []*Parameter
+	segs := p.Segments()
+	parts := make([]string, len(segs))
+	for i, e := range segs {
+		if e.Wildcard() || e.Multi {
+			return "", nil, false
+		}
+		if e.Var == "" {
+			parts[i] = e.Text
+			continue
+		}
+		parts[i] = "{" + e.Var + "}"
+		params = append(params, &Parameter{
+			Name:     e.Var,
+			In:       "path",
+			Required: true,
+			Schema:   constraintSchema(e.Constraint),
+		})
+	}
+	tmpl := strings.Join(parts, "/")
+	if tmpl == "" {
+		tmpl = "/"
+	}
+	return tmpl, params, true
+}
+
+// Describe the values matched by a path variable constraint
+func constraintSchema(x string) *Schema {
+	switch x {
+	case "":
+		return &Schema{Type: "string"}
+	case "int":
+		return &Schema{Type: "integer"}
+	case "uuid":
+		return &Schema{Type: "string", Format: "uuid"}
+	case "alpha":
+		return &Schema{Type: "string", Pattern: "^[A-Za-z]+$"}
+	case "hex":
+		return &Schema{Type: "string", Pattern: "^[0-9A-Fa-f]+$"}
+	default:
+		return &Schema{Type: "string", Pattern: "^(?:" + x + ")$"}
+	}
+}
+
+// Describe a string parameter which must have one of the provided values, or
+// any value if none are provided
+func enumSchema(v []string) *Schema {
+	s := &Schema{Type: "string"}
+	for _, e := range v {
+		if e != "" {
+			s.Enum = append(s.Enum, e)
+		}
+	}
+	return s
+}
+
+// Describe an entity in each of the provided media types, or as JSON if none
+// are provided
+func content(types []string, schema *Schema) map[string]*MediaType {
+	if len(types) == 0 {
+		types = []string{"application/json"}
+	}
+	c := make(map[string]*MediaType, len(types))
+	for _, e := range types {
+		c[e] = &MediaType{Schema: schema}
+	}
+	return c
+}
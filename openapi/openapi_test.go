@@ -0,0 +1,137 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	router "github.com/bww/go-router/v2"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Created time.Time `json:"created"`
+	Friends []*user   `json:"friends,omitempty"`
+}
+
+type updateUser struct {
+	ID     string `path:"id"`
+	Notify bool   `query:"notify"`
+	Token  string `header:"X-Token"`
+	Name   string `json:"name"`
+}
+
+func TestGenerate(t *testing.T) {
+	handler := func(*router.Request, router.Context) (*router.Response, error) {
+		return router.NewResponse(http.StatusOK), nil
+	}
+
+	api := router.New()
+	api.Add("/users/{id:uuid}", handler).Methods("GET").Name("getUser").With(
+		Summary("Get a user"),
+		Tags("users"),
+		Output(http.StatusOK, user{}),
+		Output(http.StatusNotFound, nil),
+	)
+	api.Add("/users/{id:uuid}", handler).Methods("PUT", "PATCH").Name("updateUser").With(
+		Input(updateUser{}),
+		Output(http.StatusOK, &user{}),
+	)
+	api.Add("/users/{id}", handler).Methods("GET").With(Summary("Shadowed"))
+	api.Add("/users/{id:int}", handler).Methods("PUT")
+	api.Add("/search", handler).Methods("GET").Param("type", "user").Consumes("application/json").Produces("application/json", "application/xml").With(
+		Output(http.StatusOK, []user{}),
+	)
+	api.Add("/search", handler).Methods("GET").Consumes("text/plain") // the same path does not collide
+	api.Add("/internal", handler).Methods("GET").With(Hidden())
+	api.Add("/any", handler)
+	api.Add("/files/*", handler).Methods("GET")
+	api.Add("/static/{rest...}", handler).Methods("GET")
+
+	r := router.New()
+	r.Mount("/v1", api)
+	r.Add("/", handler).Methods("GET")
+
+	doc, err := Generate(r, Info{Title: "Test", Version: "1.0"})
+	assert.Equal(t, CollisionError{
+		{Method: "GET", Path: "/v1/users/{id}", Used: "/v1/users/{id:uuid}", Other: "/v1/users/{id}"},
+		{Method: "PUT", Path: "/v1/users/{id}", Used: "/v1/users/{id:uuid}", Other: "/v1/users/{id:int}"},
+	}, err)
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Len(t, doc.Paths, 3)
+
+	item := doc.Paths["/v1/users/{id}"]
+	if assert.NotNil(t, item) && assert.Len(t, item, 3) {
+		get := item["get"]
+		if assert.NotNil(t, get) {
+			assert.Equal(t, "getUser", get.OperationID)
+			assert.Equal(t, "Get a user", get.Summary)
+			assert.Equal(t, []string{"users"}, get.Tags)
+			assert.Equal(t, []*Parameter{
+				{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string", Format: "uuid"}},
+			}, get.Parameters)
+			assert.Equal(t, &Response{
+				Description: "OK",
+				Content: map[string]*MediaType{
+					"application/json": {Schema: &Schema{Ref: "#/components/schemas/user"}},
+				},
+			}, get.Responses["200"])
+			assert.Equal(t, &Response{Description: "Not Found"}, get.Responses["404"])
+		}
+		put := item["put"]
+		if assert.NotNil(t, put) {
+			assert.Equal(t, "", put.OperationID)
+			assert.Equal(t, []*Parameter{
+				{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+				{Name: "notify", In: "query", Schema: &Schema{Type: "boolean"}},
+				{Name: "X-Token", In: "header", Schema: &Schema{Type: "string"}},
+			}, put.Parameters)
+			if assert.NotNil(t, put.RequestBody) {
+				assert.Equal(t, &Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{"name": {Type: "string"}},
+					Required:   []string{"name"},
+				}, put.RequestBody.Content["application/json"].Schema)
+			}
+		}
+	}
+
+	item = doc.Paths["/v1/search"]
+	if assert.NotNil(t, item) && assert.NotNil(t, item["get"]) {
+		get := item["get"]
+		assert.Equal(t, []*Parameter{
+			{Name: "type", In: "query", Required: true, Schema: &Schema{Type: "string", Enum: []interface{}{"user"}}},
+		}, get.Parameters)
+		assert.Len(t, get.Responses["200"].Content, 2)
+		assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/user"}}, get.Responses["200"].Content["application/xml"].Schema)
+	}
+
+	item = doc.Paths["/"]
+	if assert.NotNil(t, item) {
+		assert.Equal(t, &Response{Description: "Default response"}, item["get"].Responses["default"])
+	}
+
+	if assert.NotNil(t, doc.Components) {
+		assert.Equal(t, &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"id":      {Type: "string"},
+				"name":    {Type: "string"},
+				"created": {Type: "string", Format: "date-time"},
+				"friends": {Type: "array", Items: &Schema{Ref: "#/components/schemas/user"}},
+			},
+			Required: []string{"id", "created"},
+		}, doc.Components.Schemas["user"])
+	}
+
+	data, err := json.Marshal(doc)
+	if assert.NoError(t, err) {
+		var v map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &v))
+		assert.Equal(t, "3.1.0", v["openapi"])
+	}
+}
@@ -0,0 +1,87 @@
+package openapi
+
+import (
+	"reflect"
+
+	router "github.com/bww/go-router/v2"
+)
+
+// Route attributes which describe operations
+const (
+	AttrSummary     = "openapi.summary"
+	AttrDescription = "openapi.description"
+	AttrTags        = "openapi.tags"
+	AttrDeprecated  = "openapi.deprecated"
+	AttrInput       = "openapi.input"
+	AttrOutputs     = "openapi.outputs"
+	AttrHidden      = "openapi.hidden"
+)
+
+// Set a short summary of what a route's operation does
+func Summary(s string) router.RouteOption {
+	return func(r *router.Route) *router.Route {
+		return r.Attr(AttrSummary, s)
+	}
+}
+
+// Set a detailed description of a route's operation
+func Description(s string) router.RouteOption {
+	return func(r *router.Route) *router.Route {
+		return r.Attr(AttrDescription, s)
+	}
+}
+
+// Add tags to a route's operation
+func Tags(t ...string) router.RouteOption {
+	return func(r *router.Route) *router.Route {
+		prev, _ := r.Info().Attrs[AttrTags].([]string)
+		return r.Attr(AttrTags, append(append([]string(nil), prev...), t...))
+	}
+}
+
+// Mark a route's operation as deprecated
+func Deprecated() router.RouteOption {
+	return func(r *router.Route) *router.Route {
+		return r.Attr(AttrDeprecated, true)
+	}
+}
+
+// Exclude a route from generated documents
+func Hidden() router.RouteOption {
+	return func(r *router.Route) *router.Route {
+		return r.Attr(AttrHidden, true)
+	}
+}
+
+// Describe the input of a route's operation using the type of the provided
+// value, which may be a reflect.Type. Fields of the type tagged with 'path',
+// 'query' or 'header' are described as parameters, as they are bound by
+// router.Typed; the remaining fields describe the request entity.
+func Input(v interface{}) router.RouteOption {
+	return func(r *router.Route) *router.Route {
+		return r.Attr(AttrInput, typeOf(v))
+	}
+}
+
+// Describe a response produced by a route's operation using the type of the
+// provided value, which may be a reflect.Type. A nil value describes a
+// response which has no entity.
+func Output(status int, v interface{}) router.RouteOption {
+	return func(r *router.Route) *router.Route {
+		prev, _ := r.Info().Attrs[AttrOutputs].(map[int]reflect.Type)
+		rsps := make(map[int]reflect.Type, len(prev)+1)
+		for k, e := range prev {
+			rsps[k] = e
+		}
+		rsps[status] = typeOf(v)
+		return r.Attr(AttrOutputs, rsps)
+	}
+}
+
+// Obtain the type of a value, which may itself be a type
+func typeOf(v interface{}) reflect.Type {
+	if t, ok := v.(reflect.Type); ok {
+		return t
+	}
+	return reflect.TypeOf(v)
+}
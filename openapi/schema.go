@@ -0,0 +1,179 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// A JSON Schema, as used by OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Produces schemas for Go types by reflection. Named struct types are
+// described once, in the component schemas, and referenced elsewhere.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// Produce a schema for a type, as it is encoded as JSON
+func (s *schemas) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return &Schema{} // we can't know what this produces
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: new(float64)}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		return s.structRef(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// Produce a reference to the component schema for a named struct type, or
+// describe an anonymous struct type in place
+func (s *schemas) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return s.object(t, nil)
+	}
+	if n, ok := s.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + n}
+	}
+	n := t.Name()
+	if _, ok := s.components[n]; ok { // a different type with the same name
+		pkg := t.PkgPath()
+		if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+			pkg = pkg[i+1:]
+		}
+		n = pkg + "." + n
+	}
+	s.names[t] = n
+	s.components[n] = &Schema{} // claim the name before describing fields; the type may be recursive
+	*s.components[n] = *s.object(t, nil)
+	return &Schema{Ref: "#/components/schemas/" + n}
+}
+
+// Describe a struct type as an object. Fields are named as they are by
+// encoding/json; fields which are not omitted when empty are required. Fields
+// for which the skip function returns true are excluded.
+func (s *schemas) object(t reflect.Type, skip func(reflect.StructField) bool) *Schema {
+	obj := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.fields(obj, t, skip)
+	return obj
+}
+
+func (s *schemas) fields(obj *Schema, t reflect.Type, skip func(reflect.StructField) bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if skip != nil && skip(f) {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			s.fields(obj, ft, skip) // promoted fields
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		var prop *Schema
+		if hasOption(opts, "string") {
+			prop = &Schema{Type: "string"}
+		} else {
+			prop = s.schema(f.Type)
+		}
+		obj.Properties[name] = prop
+		if !hasOption(opts, "omitempty") && !hasOption(opts, "omitzero") {
+			obj.Required = append(obj.Required, name)
+		}
+	}
+}
+
+// Is an option present in a comma-delimited list of tag options
+func hasOption(opts, o string) bool {
+	for opts != "" {
+		var e string
+		e, opts, _ = strings.Cut(opts, ",")
+		if e == o {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package path
+
+import (
+	"regexp"
+	"sync"
+)
+
+// A constraint on the values a variable component matches
+type constraint func(string) bool
+
+// Built-in constraint types
+var constraints = map[string]constraint{
+	"int":   matchInt,
+	"uuid":  matchUUID,
+	"alpha": matchAlpha,
+	"hex":   matchHex,
+	// multi-component variables match anything, like the '**' wildcard
+	multiConstraint: func(string) bool { return true },
+}
+
+// Compiled expressions for expression constraints
+var expressions sync.Map
+
+// Obtain the constraint for the provided specification; either a built-in
+// type or a regular expression. The specification is expected to have been
+// validated by checkConstraint; an invalid expression matches nothing.
+func constraintFor(x string) constraint {
+	if f, ok := constraints[x]; ok {
+		return f
+	}
+	if v, ok := expressions.Load(x); ok {
+		return v.(*regexp.Regexp).MatchString
+	}
+	re, err := compileConstraint(x)
+	if err != nil {
+		return func(string) bool { return false }
+	}
+	expressions.Store(x, re)
+	return re.MatchString
+}
+
+// Determine if a constraint specification is valid
+func checkConstraint(x string) error {
+	if _, ok := constraints[x]; ok {
+		return nil
+	}
+	_, err := compileConstraint(x)
+	return err
+}
+
+// Compile an expression constraint, anchored so that it matches the entire
+// component. Expressions which are already anchored are unaffected.
+func compileConstraint(x string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + x + ")$")
+}
+
+func matchInt(s string) bool {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for _, e := range s {
+		if e < '0' || e > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, e := range s {
+		if (e < 'a' || e > 'z') && (e < 'A' || e > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func matchHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, e := range s {
+		if !isHex(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, e := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if e != '-' {
+				return false
+			}
+		default:
+			if !isHex(e) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHex(e rune) bool {
+	return (e >= '0' && e <= '9') || (e >= 'a' && e <= 'f') || (e >= 'A' && e <= 'F')
+}
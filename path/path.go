@@ -1,6 +1,9 @@
 package path
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"strings"
 )
 
@@ -8,23 +11,41 @@ const (
 	wildOne    = component("*")
 	wildMulti  = component("**")
 	defaultSep = '/'
+
+	multiConstraint = "**"
+)
+
+var (
+	ErrMissingVar = errors.New("Missing variable")
+	ErrInvalidVar = errors.New("Invalid variable")
+	ErrWildcard   = errors.New("Cannot expand wildcard")
 )
 
 type Vars map[string]string
 
+// Copy vars and set a value in the copy, leaving the receiver unchanged
+func (v Vars) with(k, x string) Vars {
+	c := make(Vars, len(v)+1)
+	for a, b := range v {
+		c[a] = b
+	}
+	c[k] = x
+	return c
+}
+
 // A path component
 type component string
 
-// Are components equal
+// Are components equal. Variables are equal to one another regardless of
+// their names, provided they have the same constraint.
 func (c component) Equals(v component) bool {
 	if c == v {
 		return true
 	}
-	lc, lv := len(c), len(v)
-	if lc < 2 || lv < 2 {
-		return false
-	} else if c[0] == '{' && c[lc-1] == '}' && v[0] == '{' && v[lv-1] == '}' {
-		return true
+	_, cc, cok := c.variable()
+	_, vc, vok := v.variable()
+	if cok && vok {
+		return cc == vc
 	} else {
 		return false
 	}
@@ -36,15 +57,85 @@ func (c component) Matches(s string) (bool, string) {
 		return true, "" // matches everything, captures nothing
 	} else if string(c) == s {
 		return true, ""
-	} else if l := len(c); l < 2 {
+	} else if n, x, ok := c.variable(); !ok {
 		return false, ""
-	} else if c[0] == '{' && c[l-1] == '}' {
-		return true, strings.TrimSpace(string(c[1 : l-1])) // matches everything
+	} else if x == "" {
+		return true, n // matches everything
+	} else if constraintFor(x)(s) {
+		return true, n // matches according to the constraint
 	} else {
 		return false, ""
 	}
 }
 
+// Does a component match multiple components; that is, is it either the
+// multi-component wildcard or a multi-component variable
+func (c component) multi() bool {
+	if c == wildMulti {
+		return true
+	}
+	_, x, ok := c.variable()
+	return ok && x == multiConstraint
+}
+
+// The precedence of a component when ordering by specificity; lower values
+// are more specific: literals, then constrained variables, then variables,
+// then single-component wildcards, then multi-component wildcards.
+func (c component) rank() int {
+	if c.multi() {
+		return 4
+	} else if c == wildOne {
+		return 3
+	} else if _, x, ok := c.variable(); !ok {
+		return 0
+	} else if x != "" {
+		return 1
+	} else {
+		return 2
+	}
+}
+
+// Does a component match every string the provided component matches, when
+// both are considered as single components
+func (c component) covers(v component) bool {
+	if c == v {
+		return true
+	}
+	switch c.rank() {
+	case 0:
+		return false // only identical literals
+	case 1:
+		_, x, _ := c.variable()
+		if v.rank() == 0 {
+			return constraintFor(x)(string(v))
+		} else if _, y, ok := v.variable(); ok {
+			return x == y
+		} else {
+			return false
+		}
+	default:
+		return true // anything which matches a single component
+	}
+}
+
+// Obtain the name and constraint of a variable component. If the component
+// is not a variable, false is returned. The forms {name...} and {name:**}
+// are equivalent and describe a multi-component variable.
+func (c component) variable() (string, string, bool) {
+	l := len(c)
+	if l < 2 || c[0] != '{' || c[l-1] != '}' {
+		return "", "", false
+	}
+	v := string(c[1 : l-1])
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		return strings.TrimSpace(v[:i]), strings.TrimSpace(v[i+1:]), true
+	} else if n := strings.TrimSpace(v); strings.HasSuffix(n, "...") {
+		return strings.TrimSpace(n[:len(n)-3]), multiConstraint, true
+	} else {
+		return n, "", true
+	}
+}
+
 func joinCmp(c []component, sep rune) string {
 	b := strings.Builder{}
 	for i, e := range c {
@@ -62,22 +153,34 @@ type Path struct {
 	sep rune
 }
 
-// Split a path into (first component, remainder)
+// Split a path into (first component, remainder). When vars are considered,
+// separators inside of braces, which may be nested, do not split the path.
 func splitPath(s string, sep rune, vars bool) (string, string) {
-	var invar bool
+	var depth int
 	for i, e := range s {
-		if e == sep && !invar {
+		if e == sep && depth == 0 {
 			return s[:i], s[i+1:]
 		} else if vars && e == '{' {
-			invar = true
-		} else if vars && e == '}' {
-			invar = false
+			depth++
+		} else if vars && e == '}' && depth > 0 {
+			depth--
 		}
 	}
 	return s, ""
 }
 
-// Parse a path using the default separator '/'
+// Parse a path using the default separator '/'.
+//
+// Variable components may be constrained by a type or a regular expression,
+// following the variable name and a colon: {name:type} or {name:expr}. The
+// supported types are: int, uuid, alpha, and hex; any other constraint is
+// interpreted as a regular expression which must match the entire component.
+// A variable only matches components which satisfy its constraint. Parsing a
+// path with an invalid expression panics.
+//
+// A variable in the final component of a path may capture the remainder of
+// the path, in the manner of the '**' wildcard, by using the form {name...}
+// or, equivalently, {name:**}.
 func Parse(s string) Path {
 	return ParseSeparator(s, defaultSep)
 }
@@ -88,6 +191,11 @@ func ParseSeparator(s string, sep rune) Path {
 	var c string
 	for s != "" {
 		c, s = splitPath(s, sep, true)
+		if _, x, ok := component(c).variable(); ok && x != "" {
+			if err := checkConstraint(x); err != nil {
+				panic(fmt.Errorf("path: invalid constraint in %s: %w", c, err))
+			}
+		}
 		p = append(p, component(c))
 	}
 	return Path{
@@ -100,9 +208,11 @@ func ParseSeparator(s string, sep rune) Path {
 func (p Path) Matches(s string) (bool, Vars) {
 	var vars map[string]string
 	var c string
-	var e component
-	for _, e = range p.cmp {
+	for i, e := range p.cmp {
 		c, s = splitPath(s, p.sep, false)
+		if s != "" && i == len(p.cmp)-1 && e.multi() {
+			c, s = c+string(p.sep)+s, "" // the final multi component consumes the remainder
+		}
 		m, n := e.Matches(c)
 		if !m {
 			return false, nil
@@ -114,13 +224,152 @@ func (p Path) Matches(s string) (bool, Vars) {
 			vars[n] = c
 		}
 	}
-	if s != "" && e != wildMulti {
+	if s != "" {
 		return false, nil
 	}
 	return true, vars
 }
 
+// Does a path match a prefix of the provided string. If so, the vars captured
+// by the match and the remainder of the string following the matched prefix
+// are returned. Multi-component wildcards match only a single component.
+func (p Path) MatchesPrefix(s string) (bool, Vars, string) {
+	var vars map[string]string
+	var c string
+	for _, e := range p.cmp {
+		c, s = splitPath(s, p.sep, false)
+		m, n := e.Matches(c)
+		if !m {
+			return false, nil, ""
+		}
+		if n != "" {
+			if vars == nil {
+				vars = make(map[string]string)
+			}
+			vars[n] = c
+		}
+	}
+	return true, vars, s
+}
+
+// Compare the specificity of two paths. The result is negative if the
+// receiver is more specific than the provided path, positive if it is less
+// specific, and zero if they are equally specific. Components are compared
+// in order: literals are more specific than variables with constraints, which
+// are more specific than variables, which are more specific than '*', which
+// is more specific than '**'. When one path is a prefix of the other, the
+// shorter path is more specific.
+func (p Path) Compare(o Path) int {
+	for i, e := range p.cmp {
+		if i >= len(o.cmp) {
+			return 1
+		}
+		if d := e.rank() - o.cmp[i].rank(); d != 0 {
+			return d
+		}
+	}
+	return len(p.cmp) - len(o.cmp)
+}
+
+// Covers determines if the receiver matches every path that the provided
+// path matches. This is a conservative check: when it is not possible to
+// determine coverage from the templates alone, false is returned.
+func (p Path) Covers(o Path) bool {
+	for i, e := range p.cmp {
+		last := i == len(p.cmp)-1
+		if last && e.multi() {
+			return true // a final multi component matches any remainder
+		}
+		if i >= len(o.cmp) {
+			return false
+		}
+		if v := o.cmp[i]; i == len(o.cmp)-1 && v.multi() {
+			return false // only a final multi component covers another
+		} else if !e.covers(v) {
+			return false
+		}
+	}
+	return len(p.cmp) == len(o.cmp)
+}
+
+// A segment of a path, which is literal text, a wildcard or a variable
+type Segment struct {
+	Text       string // the segment as it appears in the path
+	Var        string // the name of the variable, if the segment is one
+	Constraint string // the constraint on the variable, if any
+	Multi      bool   // does the segment match multiple components
+}
+
+// Is the segment a wildcard
+func (s Segment) Wildcard() bool {
+	return s.Text == string(wildOne) || s.Text == string(wildMulti)
+}
+
+// Obtain the segments of the path, in order. Like the components of the path
+// itself, the segments of an absolute path begin with an empty segment.
+func (p Path) Segments() []Segment {
+	res := make([]Segment, len(p.cmp))
+	for i, e := range p.cmp {
+		seg := Segment{Text: string(e), Multi: e.multi()}
+		if n, x, ok := e.variable(); ok {
+			seg.Var = n
+			if x != multiConstraint {
+				seg.Constraint = x
+			}
+		}
+		res[i] = seg
+	}
+	return res
+}
+
 // Describe this path
 func (p Path) String() string {
 	return joinCmp(p.cmp, p.sep)
 }
+
+// Expand a path by substituting the provided values for its variables. Every
+// variable must have a value, and that value must satisfy the variable's
+// constraint. Values are escaped for use in a URL path; the values of multi-
+// component variables may contain separators, which are preserved. Paths
+// which contain wildcards cannot be expanded.
+func (p Path) Expand(vars Vars) (string, error) {
+	sep := string(p.sep)
+	b := strings.Builder{}
+	for i, e := range p.cmp {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		if e == wildOne || e == wildMulti {
+			return "", fmt.Errorf("%w: %s", ErrWildcard, e)
+		}
+		n, x, ok := e.variable()
+		if !ok {
+			b.WriteString(string(e))
+			continue
+		}
+		v, ok := vars[n]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingVar, n)
+		}
+		if x == multiConstraint {
+			for j, c := range strings.Split(v, sep) {
+				if j > 0 {
+					b.WriteString(sep)
+				}
+				b.WriteString(url.PathEscape(c))
+			}
+			continue
+		}
+		if strings.Contains(v, sep) {
+			return "", fmt.Errorf("%w: %s: value contains separator", ErrInvalidVar, n)
+		}
+		if x != "" && !constraintFor(x)(v) {
+			return "", fmt.Errorf("%w: %s: value does not satisfy constraint: %s", ErrInvalidVar, n, x)
+		}
+		b.WriteString(url.PathEscape(v))
+	}
+	if b.Len() == 0 {
+		return sep, nil // the root path is a single, empty component
+	}
+	return b.String(), nil
+}
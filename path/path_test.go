@@ -142,3 +142,254 @@ func TestPathsSep(t *testing.T) {
 		assert.Equal(t, e.Vars, v)
 	}
 }
+
+func TestPathConstraints(t *testing.T) {
+	tests := []struct {
+		Path   string
+		Match  string
+		Expect bool
+		Vars   Vars
+	}{
+		{
+			"/a/{id:int}", "/a/123", true, Vars{"id": "123"},
+		},
+		{
+			"/a/{id:int}", "/a/-1", true, Vars{"id": "-1"},
+		},
+		{
+			"/a/{id:int}", "/a/me", false, nil,
+		},
+		{
+			"/a/{id:int}", "/a", false, nil,
+		},
+		{
+			"/a/{id:uuid}", "/a/0b6c3a6e-3f7b-4c3e-9d1a-5e2f8a9b7c6d", true, Vars{"id": "0b6c3a6e-3f7b-4c3e-9d1a-5e2f8a9b7c6d"},
+		},
+		{
+			"/a/{id:uuid}", "/a/0b6c3a6e3f7b4c3e9d1a5e2f8a9b7c6d", false, nil,
+		},
+		{
+			"/a/{v:alpha}", "/a/abcXYZ", true, Vars{"v": "abcXYZ"},
+		},
+		{
+			"/a/{v:alpha}", "/a/abc1", false, nil,
+		},
+		{
+			"/a/{v:hex}", "/a/deadBEEF01", true, Vars{"v": "deadBEEF01"},
+		},
+		{
+			"/a/{v:hex}", "/a/xyz", false, nil,
+		},
+		{
+			"/a/{slug:^[a-z-]+$}", "/a/hello-world", true, Vars{"slug": "hello-world"},
+		},
+		{
+			"/a/{slug:[a-z-]+}", "/a/Hello", false, nil, // expressions match the entire component
+		},
+		{
+			"/a/{v:[a-z]{2,3}}/c", "/a/ab/c", true, Vars{"v": "ab"},
+		},
+		{
+			"/a/{v:[a-z]{2,3}}/c", "/a/abcd/c", false, nil,
+		},
+	}
+	for _, e := range tests {
+		m, v := Parse(e.Path).Matches(e.Match)
+		assert.Equal(t, e.Expect, m, e.Path)
+		assert.Equal(t, e.Vars, v, e.Path)
+	}
+}
+
+func TestPathInvalidConstraint(t *testing.T) {
+	assert.Panics(t, func() {
+		Parse("/a/{v:[a-z}")
+	})
+}
+
+func TestPathMultiVars(t *testing.T) {
+	tests := []struct {
+		Path   string
+		Match  string
+		Expect bool
+		Vars   Vars
+	}{
+		{
+			"/a/{rest...}", "/a/b/c/d", true, Vars{"rest": "b/c/d"},
+		},
+		{
+			"/a/{rest:**}", "/a/b/c/d", true, Vars{"rest": "b/c/d"},
+		},
+		{
+			"/a/{rest...}", "/a/b", true, Vars{"rest": "b"},
+		},
+		{
+			"/a/{rest...}", "/a", true, Vars{"rest": ""},
+		},
+		{
+			"/a/{rest...}", "/x/b", false, nil,
+		},
+		{
+			"/a/{id}/{rest...}", "/a/1/b/c", true, Vars{"id": "1", "rest": "b/c"},
+		},
+		{
+			"/a/{mid...}/c", "/a/b/c", true, Vars{"mid": "b"}, // only the final component captures the remainder
+		},
+	}
+	for _, e := range tests {
+		m, v := Parse(e.Path).Matches(e.Match)
+		assert.Equal(t, e.Expect, m, e.Path)
+		assert.Equal(t, e.Vars, v, e.Path)
+	}
+}
+
+func TestPathExpand(t *testing.T) {
+	tests := []struct {
+		Path   string
+		Vars   Vars
+		Expect string
+		Error  error
+	}{
+		{
+			"/", nil, "/", nil,
+		},
+		{
+			"/a/b", nil, "/a/b", nil,
+		},
+		{
+			"/a/{var}", Vars{"var": "b"}, "/a/b", nil,
+		},
+		{
+			"/a/{var}/c", Vars{"var": "b c"}, "/a/b%20c/c", nil,
+		},
+		{
+			"/a/{id:int}", Vars{"id": "123"}, "/a/123", nil,
+		},
+		{
+			"/a/{rest...}", Vars{"rest": "b/c d"}, "/a/b/c%20d", nil,
+		},
+		{
+			"/a/{var}", Vars{}, "", ErrMissingVar,
+		},
+		{
+			"/a/{id:int}", Vars{"id": "me"}, "", ErrInvalidVar,
+		},
+		{
+			"/a/{var}", Vars{"var": "b/c"}, "", ErrInvalidVar,
+		},
+		{
+			"/a/*", nil, "", ErrWildcard,
+		},
+	}
+	for _, e := range tests {
+		s, err := Parse(e.Path).Expand(e.Vars)
+		if e.Error != nil {
+			assert.ErrorIs(t, err, e.Error, e.Path)
+		} else if assert.NoError(t, err, e.Path) {
+			assert.Equal(t, e.Expect, s, e.Path)
+		}
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	tests := []struct {
+		Path   string
+		Match  string
+		Expect bool
+		Vars   Vars
+		Rest   string
+	}{
+		{
+			"/a", "/a/b/c", true, nil, "b/c",
+		},
+		{
+			"/a", "/a", true, nil, "",
+		},
+		{
+			"/", "/a/b", true, nil, "a/b",
+		},
+		{
+			"/a/{var}", "/a/b/c", true, Vars{"var": "b"}, "c",
+		},
+		{
+			"/a", "/b/c", false, nil, "",
+		},
+	}
+	for _, e := range tests {
+		m, v, r := Parse(e.Path).MatchesPrefix(e.Match)
+		assert.Equal(t, e.Expect, m, e.Path)
+		assert.Equal(t, e.Vars, v, e.Path)
+		assert.Equal(t, e.Rest, r, e.Path)
+	}
+}
+
+func TestPathCompare(t *testing.T) {
+	tests := []struct {
+		A, B   string
+		Expect int // sign only
+	}{
+		{"/a/b", "/a/b", 0},
+		{"/a/b", "/a/{var}", -1},
+		{"/a/{id:int}", "/a/{var}", -1},
+		{"/a/{var}", "/a/*", -1},
+		{"/a/*", "/a/**", -1},
+		{"/a/{var}", "/a/{rest...}", -1},
+		{"/a/**", "/a/b/c", 1},
+		{"/a", "/a/**", -1},
+		{"/a/{x}", "/a/{y}", 0},
+		{"/{x}/b", "/a/{y}", 1},
+	}
+	for _, e := range tests {
+		c := Parse(e.A).Compare(Parse(e.B))
+		switch {
+		case e.Expect < 0:
+			assert.Less(t, c, 0, e.A+" <> "+e.B)
+		case e.Expect > 0:
+			assert.Greater(t, c, 0, e.A+" <> "+e.B)
+		default:
+			assert.Equal(t, 0, c, e.A+" <> "+e.B)
+		}
+	}
+}
+
+func TestPathCovers(t *testing.T) {
+	tests := []struct {
+		A, B   string
+		Expect bool
+	}{
+		{"/a/b", "/a/b", true},
+		{"/a/{x}", "/a/{y}", true},
+		{"/a/{x}", "/a/b", true},
+		{"/a/{x}", "/a/{id:int}", true},
+		{"/a/*", "/a/{y}", true},
+		{"/a/{id:int}", "/a/{x}", false},
+		{"/a/{id:int}", "/a/123", true},
+		{"/a/{id:int}", "/a/me", false},
+		{"/a/b", "/a/{x}", false},
+		{"/a/**", "/a/b/c", true},
+		{"/a/**", "/a", true},
+		{"/a/{rest...}", "/a/**", true},
+		{"/a/{x}", "/a/**", false},
+		{"/a/{x}", "/a/b/c", false},
+		{"/a/b/c", "/a/b", false},
+	}
+	for _, e := range tests {
+		assert.Equal(t, e.Expect, Parse(e.A).Covers(Parse(e.B)), e.A+" covers "+e.B)
+	}
+}
+
+func TestPathSegments(t *testing.T) {
+	assert.Equal(t, []Segment{
+		{Text: ""},
+		{Text: "a"},
+		{Text: "{id:int}", Var: "id", Constraint: "int"},
+		{Text: "*"},
+		{Text: "{b}", Var: "b"},
+		{Text: "{rest...}", Var: "rest", Multi: true},
+	}, Parse("/a/{id:int}/*/{b}/{rest...}").Segments())
+	segs := Parse("/a/**").Segments()
+	if assert.Len(t, segs, 3) {
+		assert.False(t, segs[1].Wildcard())
+		assert.True(t, segs[2].Wildcard())
+		assert.True(t, segs[2].Multi)
+	}
+}
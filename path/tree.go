@@ -34,6 +34,8 @@ func (t *Tree[T]) separator() rune {
 	}
 }
 
+// Add a value for the provided template. If a value is already set for an
+// equivalent template ErrCollision is returned.
 func (t *Tree[T]) Add(p string, v T) error {
 	return t.add(ParseSeparator(p, t.separator()).cmp, v)
 }
@@ -73,46 +75,100 @@ func (t *Tree[T]) add(p []component, v T) error {
 	return nil
 }
 
+// Find the first value whose template matches the provided path
 func (t *Tree[T]) Find(s string) (T, Vars, bool) {
-	return t.find(s, Vars{})
+	var (
+		val   T
+		vars  Vars
+		found bool
+	)
+	t.FindFunc(s, func(v T, x Vars) bool {
+		val, vars, found = v, x, true
+		return false
+	})
+	return val, vars, found
 }
 
-func (t *Tree[T]) find(s string, vars Vars) (T, Vars, bool) {
+// FindFunc visits every value whose template matches the provided path, in
+// the order the tree is searched, until the visitor returns false. The vars
+// provided to the visitor are specific to each match.
+func (t *Tree[T]) FindFunc(s string, f func(T, Vars) bool) {
+	t.find(s, Vars{}, true, f)
+}
+
+// MatchFunc visits every value whose template matches the provided path, in
+// the order the tree is searched, until the visitor returns false. Unlike
+// FindFunc, variables are not captured, which is cheaper when the caller
+// doesn't need them.
+func (t *Tree[T]) MatchFunc(s string, f func(T) bool) {
+	t.find(s, nil, false, func(v T, _ Vars) bool {
+		return f(v)
+	})
+}
+
+func (t *Tree[T]) find(s string, vars Vars, capture bool, f func(T, Vars) bool) bool {
 	c, r := splitPath(s, t.separator(), false)
-	var zero T
 
-	// search for a match in this node
+	// search for matches in this node
 	for _, e := range t.n {
-		if m, v := e.cmp.Matches(c); m {
-			if e.sub == nil {
-				if r == "" {
-					if v != "" {
-						vars[v] = c
-					}
-					return e.value, vars, true
-				}
-			} else {
-				if r == "" {
-					if e.isset {
-						if v != "" {
-							vars[v] = c
-						}
-						return e.value, vars, true
-					} else {
-						break
-					}
-				} else if val, x, ok := e.sub.find(r, vars); ok {
-					if v != "" {
-						vars[v] = c
-					}
-					return val, x, ok
-				}
+		m, v := e.cmp.Matches(c)
+		if !m {
+			continue
+		}
+		x := vars
+		if capture && v != "" {
+			x = vars.with(v, c)
+		}
+		if e.isset && r == "" {
+			if !f(e.value, x) {
+				return false
+			}
+		} else if e.isset && e.cmp.multi() {
+			// a terminal multi component matches any remainder
+			y := vars
+			if capture && v != "" {
+				y = vars.with(v, c+string(t.separator())+r)
+			}
+			if !f(e.value, y) {
+				return false
+			}
+		}
+		// when the path is exhausted we still descend, since components may
+		// match the empty string, which is consistent with Path.Matches
+		if e.sub != nil {
+			if !e.sub.find(r, x, capture, f) {
+				return false
 			}
 		}
 	}
 
-	// not found
-	return zero, nil, false
+	return true
+}
+
+// Lookup the value stored for exactly the provided template, if any. Templates
+// are compared the same way they are when adding values, so variable names
+// are not significant.
+func (t *Tree[T]) Lookup(p string) (T, bool) {
+	return t.lookup(ParseSeparator(p, t.separator()).cmp)
+}
+
+func (t *Tree[T]) lookup(p []component) (T, bool) {
+	var zero T
+	if len(p) == 0 {
+		return zero, false
+	}
+	for _, e := range t.n {
+		if e.cmp.Equals(p[0]) {
+			if len(p) > 1 {
+				if e.sub == nil {
+					return zero, false
+				}
+				return e.sub.lookup(p[1:])
+			}
+			return e.value, e.isset
+		}
+	}
+	return zero, false
 }
 
 func (t *Tree[T]) Iter(f func(string, T) bool) {
@@ -85,6 +85,115 @@ func TestTree(t *testing.T) {
 
 }
 
+func TestTreeFindFunc(t *testing.T) {
+	tree := &Tree[string]{}
+	tree.Add("/a/{var}", "/a/{var}")
+	tree.Add("/a/b", "/a/b")
+	tree.Add("/a/**", "/a/**")
+	tree.Add("/*/b", "/*/b")
+
+	tests := []struct {
+		Path   string
+		Expect []string
+		Vars   []Vars
+	}{
+		{
+			"/a/b", []string{"/a/{var}", "/a/b", "/a/**", "/*/b"}, []Vars{{"var": "b"}, {}, {}, {}},
+		},
+		{
+			"/a/b/c", []string{"/a/**"}, []Vars{{}},
+		},
+		{
+			"/a", []string{"/a/{var}", "/a/**"}, []Vars{{"var": ""}, {}},
+		},
+		{
+			"/x/y", nil, nil,
+		},
+	}
+	for i, e := range tests {
+		var (
+			vals []string
+			vars []Vars
+		)
+		tree.FindFunc(e.Path, func(v string, x Vars) bool {
+			vals = append(vals, v)
+			vars = append(vars, x)
+			return true
+		})
+		assert.Equal(t, e.Expect, vals, fmt.Sprintf("#%d: %s", i, e.Path))
+		assert.Equal(t, e.Vars, vars, fmt.Sprintf("#%d: %s", i, e.Path))
+
+		vals = nil
+		tree.MatchFunc(e.Path, func(v string) bool {
+			vals = append(vals, v)
+			return true
+		})
+		assert.Equal(t, e.Expect, vals, fmt.Sprintf("#%d: %s", i, e.Path))
+	}
+}
+
+func TestTreeConstraints(t *testing.T) {
+	tree := &Tree[string]{}
+	assert.NoError(t, tree.Add("/a/{id:int}", "int"))
+	assert.NoError(t, tree.Add("/a/{id:alpha}", "alpha"))
+	assert.NoError(t, tree.Add("/a/{id}", "any"))
+	assert.Equal(t, ErrCollision, tree.Add("/a/{other:int}", "int"))
+
+	v, x, ok := tree.Find("/a/123")
+	assert.True(t, ok)
+	assert.Equal(t, "int", v)
+	assert.Equal(t, Vars{"id": "123"}, x)
+
+	v, _, ok = tree.Find("/a/abc")
+	assert.True(t, ok)
+	assert.Equal(t, "alpha", v)
+
+	v, _, ok = tree.Find("/a/a-1")
+	assert.True(t, ok)
+	assert.Equal(t, "any", v)
+}
+
+func TestTreeMultiVars(t *testing.T) {
+	tree := &Tree[string]{}
+	assert.NoError(t, tree.Add("/a/b", "/a/b"))
+	assert.NoError(t, tree.Add("/a/{rest...}", "/a/{rest...}"))
+	assert.Equal(t, ErrCollision, tree.Add("/a/{other:**}", "/a/{other:**}"))
+
+	v, x, ok := tree.Find("/a/b/c/d")
+	assert.True(t, ok)
+	assert.Equal(t, "/a/{rest...}", v)
+	assert.Equal(t, Vars{"rest": "b/c/d"}, x)
+
+	v, x, ok = tree.Find("/a/b")
+	assert.True(t, ok)
+	assert.Equal(t, "/a/b", v)
+	assert.Equal(t, Vars{}, x)
+
+	v, x, ok = tree.Find("/a/c")
+	assert.True(t, ok)
+	assert.Equal(t, "/a/{rest...}", v)
+	assert.Equal(t, Vars{"rest": "c"}, x)
+}
+
+func TestTreeLookup(t *testing.T) {
+	tree := &Tree[string]{}
+	tree.Add("/a/{var}", "/a/{var}")
+	tree.Add("/a/b", "/a/b")
+
+	v, ok := tree.Lookup("/a/{other}")
+	assert.True(t, ok)
+	assert.Equal(t, "/a/{var}", v)
+
+	v, ok = tree.Lookup("/a/b")
+	assert.True(t, ok)
+	assert.Equal(t, "/a/b", v)
+
+	_, ok = tree.Lookup("/a")
+	assert.False(t, ok)
+	_, ok = tree.Lookup("/a/b/c")
+	assert.False(t, ok)
+}
+
 func TestTreeIter(t *testing.T) {
 	tree := &Tree[string]{}
 	tree.Add("/a", "/a")
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// A recover option
+type RecoverOption func(RecoverConfig) RecoverConfig
+
+// Recover middleware configuration
+type RecoverConfig struct {
+	// The logger panics are reported to. If nil, the default logger is used.
+	Logger *slog.Logger
+	// Re-raise the panic after it has been logged instead of producing a
+	// response. This is mainly useful in tests.
+	Repanic bool
+}
+
+// Set the logger panics are reported to
+func WithRecoverLogger(l *slog.Logger) RecoverOption {
+	return func(c RecoverConfig) RecoverConfig {
+		c.Logger = l
+		return c
+	}
+}
+
+// Re-raise panics after they have been logged
+func WithRepanic(on bool) RecoverOption {
+	return func(c RecoverConfig) RecoverConfig {
+		c.Repanic = on
+		return c
+	}
+}
+
+// Recover produces middleware which recovers from panics in the handlers it
+// wraps. The panic is logged along with the stack and the route which was
+// handling the request, and a 500 response is produced in place of the
+// handler's response.
+//
+// Panics with the value http.ErrAbortHandler are always re-raised, since they
+// are intended to abort the response.
+func Recover(opts ...RecoverOption) Middle {
+	var conf RecoverConfig
+	for _, opt := range opts {
+		conf = opt(conf)
+	}
+	return recoverer{conf}
+}
+
+type recoverer struct {
+	conf RecoverConfig
+}
+
+func (r recoverer) String() string {
+	return "Recover"
+}
+
+func (r recoverer) Wrap(h Handler) Handler {
+	return func(req *Request, cxt Context) (rsp *Response, err error) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			log := r.conf.Logger
+			if log == nil {
+				log = slog.Default()
+			}
+			log = log.With("method", req.Method, "path", req.URL.Path, "panic", v, "stack", string(debug.Stack()))
+			if match := MatchFromContext(req.Context()); match != nil && match.Route != nil {
+				log = log.With("route", match.Route.Describe(true))
+			}
+			log.Error("Recovered from panic while handling request")
+			if r.conf.Repanic {
+				panic(v)
+			}
+			rsp, err = NewError(http.StatusInternalServerError, "Internal server error").Response(), nil
+		}()
+		return h(req, cxt)
+	}
+}
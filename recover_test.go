@@ -0,0 +1,52 @@
+package router
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := slog.New(slog.NewTextHandler(buf, nil))
+
+	r := New()
+	r.Use(Recover(WithRecoverLogger(log)))
+	r.Add("/a", func(*Request, Context) (*Response, error) {
+		panic("Something went wrong")
+	}).Methods("GET")
+	r.Add("/b", func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "B")
+	}).Methods("GET")
+
+	req, err := NewRequest("GET", "/a", nil)
+	if assert.NoError(t, err) {
+		rsp, err := r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusInternalServerError, rsp.Status)
+			assert.Equal(t, "application/problem+json", rsp.Header.Get("Content-Type"))
+		}
+		assert.Contains(t, buf.String(), "Something went wrong")
+		assert.Contains(t, buf.String(), "route=\"GET /a (")
+		assert.Contains(t, buf.String(), "stack=")
+	}
+	req, err = NewRequest("GET", "/b", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("B"), nil)
+	}
+
+	r = New()
+	r.Use(Recover(WithRecoverLogger(log), WithRepanic(true)))
+	r.Add("/a", func(*Request, Context) (*Response, error) {
+		panic("Something went wrong")
+	})
+	req, err = NewRequest("GET", "/a", nil)
+	if assert.NoError(t, err) {
+		assert.PanicsWithValue(t, "Something went wrong", func() {
+			r.Handle(req)
+		})
+	}
+}
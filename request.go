@@ -2,14 +2,22 @@ package router
 
 import (
 	"context"
+	"errors"
 	"io"
+	"mime"
 	"net"
 	"net/http"
 	"strings"
+
+	"github.com/bww/go-router/v2/entity"
 )
 
 const hdrXForwardedFor = "X-Forwarded-For"
 
+// The maximum size of a request entity which is decoded, unless otherwise
+// specified by DecodeOptions.MaxBytes
+const DefaultMaxEntityBytes = 10 << 20
+
 type Request http.Request
 
 func NewRequest(method, path string, entity io.Reader) (*Request, error) {
@@ -50,6 +58,56 @@ func (r *Request) OriginAddr() string {
 	}
 }
 
+// Decode the request entity into the provided value using the decoder which
+// is registered in the entity package for the request's Content-Type. The
+// default decode options are used.
+func (r *Request) Decode(v interface{}) error {
+	return r.DecodeWith(v, entity.DecodeOptions{})
+}
+
+// DecodeWith decodes the request entity into the provided value using the
+// specified options. If options do not specify a maximum entity size,
+// DefaultMaxEntityBytes is used; a negative size disables the limit.
+//
+// Errors are reported as an *HTTPError which describes the status that
+// should be produced: 415 if the Content-Type is missing or no decoder is
+// registered for it, 413 if the entity is too large, and 400 otherwise.
+func (r *Request) DecodeWith(v interface{}, opts entity.DecodeOptions) error {
+	t := r.Header.Get("Content-Type")
+	if t == "" {
+		return NewError(http.StatusUnsupportedMediaType, "No content type")
+	}
+	mtype, params, err := mime.ParseMediaType(t)
+	if err != nil {
+		return NewError(http.StatusUnsupportedMediaType, "Invalid content type").SetCause(err)
+	}
+	dec, ok := entity.LookupDecoder(mtype)
+	if !ok {
+		return Errorf(http.StatusUnsupportedMediaType, "Unsupported content type: %s", mtype)
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return NewError(http.StatusBadRequest, "No entity")
+	}
+
+	if opts.MaxBytes == 0 {
+		opts.MaxBytes = DefaultMaxEntityBytes
+	}
+	var body io.Reader = r.Body
+	if opts.MaxBytes > 0 {
+		body = http.MaxBytesReader(nil, r.Body, opts.MaxBytes)
+	}
+
+	err = dec(body, params, v, opts)
+	if err != nil {
+		var errMax *http.MaxBytesError
+		if errors.As(err, &errMax) {
+			return NewError(http.StatusRequestEntityTooLarge, "Entity too large").SetCause(err)
+		}
+		return NewError(http.StatusBadRequest, "Could not decode entity").SetCause(err)
+	}
+	return nil
+}
+
 func parseForwardedFor(h string) string {
 	p := strings.Split(h, ",")
 	if l := len(p); l > 0 {
@@ -1,8 +1,14 @@
 package router
 
 import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/bww/go-router/v2/entity"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,3 +54,93 @@ func TestOriginAddr(t *testing.T) {
 		assert.Equal(t, e.Req.OriginAddr(), e.Expect)
 	}
 }
+
+func TestRequestDecode(t *testing.T) {
+	type value struct {
+		Name  string   `json:"name" form:"name"`
+		Count int      `json:"count" form:"count"`
+		Tags  []string `json:"tags" form:"tag"`
+	}
+
+	multi := &bytes.Buffer{}
+	mw := multipart.NewWriter(multi)
+	mw.WriteField("name", "A")
+	mw.WriteField("count", "3")
+	fw, _ := mw.CreateFormFile("upload", "a.txt")
+	fw.Write([]byte("File contents"))
+	mw.Close()
+
+	tests := []struct {
+		Type   string
+		Entity string
+		Opts   entity.DecodeOptions
+		Expect value
+		Status int
+	}{
+		{
+			"application/json", `{"name":"A","count":1,"tags":["x","y"]}`, entity.DecodeOptions{}, value{Name: "A", Count: 1, Tags: []string{"x", "y"}}, 0,
+		},
+		{
+			"application/json; charset=utf-8", `{"name":"A","other":true}`, entity.DecodeOptions{}, value{Name: "A"}, 0,
+		},
+		{
+			"application/json", `{"name":"A","other":true}`, entity.DecodeOptions{DisallowUnknownFields: true}, value{}, http.StatusBadRequest,
+		},
+		{
+			"application/json", `{"name":`, entity.DecodeOptions{}, value{}, http.StatusBadRequest,
+		},
+		{
+			"application/json", `{"name":"This is too long"}`, entity.DecodeOptions{MaxBytes: 8}, value{}, http.StatusRequestEntityTooLarge,
+		},
+		{
+			"application/x-www-form-urlencoded", `name=A&count=2&tag=x&tag=y`, entity.DecodeOptions{}, value{Name: "A", Count: 2, Tags: []string{"x", "y"}}, 0,
+		},
+		{
+			"application/x-www-form-urlencoded", `name=A&other=1`, entity.DecodeOptions{DisallowUnknownFields: true}, value{}, http.StatusBadRequest,
+		},
+		{
+			"application/x-www-form-urlencoded", `count=nope`, entity.DecodeOptions{}, value{}, http.StatusBadRequest,
+		},
+		{
+			"image/png", `...`, entity.DecodeOptions{}, value{}, http.StatusUnsupportedMediaType,
+		},
+		{
+			"", `...`, entity.DecodeOptions{}, value{}, http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, e := range tests {
+		req, err := NewRequest("POST", "/", strings.NewReader(e.Entity))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		if e.Type != "" {
+			req.Header.Set("Content-Type", e.Type)
+		}
+		var v value
+		err = req.DecodeWith(&v, e.Opts)
+		if e.Status != 0 {
+			var derr *HTTPError
+			if assert.ErrorAs(t, err, &derr, e.Entity) {
+				assert.Equal(t, e.Status, derr.Status, e.Entity)
+				assert.Equal(t, e.Status, derr.Response().Status, e.Entity)
+			}
+		} else if assert.NoError(t, err, e.Entity) {
+			assert.Equal(t, e.Expect, v, e.Entity)
+		}
+	}
+
+	req, err := NewRequest("POST", "/", multi)
+	if assert.NoError(t, err) {
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		var v struct {
+			value
+			Upload *multipart.FileHeader `form:"upload"`
+		}
+		if assert.NoError(t, req.Decode(&v)) {
+			assert.Equal(t, value{Name: "A", Count: 3}, v.value)
+			if assert.NotNil(t, v.Upload) {
+				assert.Equal(t, "a.txt", v.Upload.Filename)
+			}
+		}
+	}
+}
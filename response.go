@@ -4,6 +4,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/bww/go-router/v2/entity"
 )
@@ -69,6 +70,39 @@ func (r *Response) SetJSON(d interface{}) (*Response, error) {
 	return r.SetEntity(e)
 }
 
+// SetValue encodes a value as the entity using the media type which is most
+// preferred by the request's Accept header among those for which encoders are
+// registered in the entity package. If the request doesn't specify what it
+// accepts, the first registered type is used. If no registered type is
+// acceptable, an error which matches ErrNotAcceptable is returned, which
+// produces a 406 response.
+func (r *Response) SetValue(req *Request, d interface{}) (*Response, error) {
+	types := entity.EncoderTypes()
+	if len(types) == 0 {
+		return nil, errNotAcceptable()
+	}
+	t := types[0]
+	if a := req.Header.Values("Accept"); len(a) > 0 {
+		ranges := parseQualified(strings.Join(a, ","))
+		var best float64
+		t = ""
+		for _, e := range types {
+			if q := acceptQuality(ranges, e); q > best {
+				t, best = e, q
+			}
+		}
+		if t == "" {
+			return nil, errNotAcceptable()
+		}
+	}
+	r.Header.Add("Vary", "Accept")
+	e, err := entity.NewValue(t, d)
+	if err != nil {
+		return nil, err
+	}
+	return r.SetEntity(e)
+}
+
 func (r *Response) ReadEntity() ([]byte, error) {
 	if r.Entity == nil {
 		return []byte{}, nil
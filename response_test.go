@@ -0,0 +1,85 @@
+package router
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bww/go-router/v2/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testValue struct {
+	XMLName xml.Name `json:"-" xml:"value"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func TestResponseSetValue(t *testing.T) {
+	entity.RegisterEncoder("application/x-test", func(v interface{}) ([]byte, error) {
+		return []byte(fmt.Sprintf("%v", v.(testValue).Name)), nil
+	})
+
+	tests := []struct {
+		Accept string
+		Type   string
+		Expect string
+		Error  error
+	}{
+		{
+			"", "application/json", `{"name":"A"}`, nil,
+		},
+		{
+			"*/*", "application/json", `{"name":"A"}`, nil,
+		},
+		{
+			"application/xml", "application/xml", `<value><name>A</name></value>`, nil,
+		},
+		{
+			"application/json;q=0.5, application/xml", "application/xml", `<value><name>A</name></value>`, nil,
+		},
+		{
+			"application/*", "application/json", `{"name":"A"}`, nil,
+		},
+		{
+			"application/x-test", "application/x-test", `A`, nil,
+		},
+		{
+			"image/png", "", "", ErrNotAcceptable,
+		},
+		{
+			"application/json;q=0, application/xml;q=0", "", "", ErrNotAcceptable,
+		},
+	}
+	for _, e := range tests {
+		req := mustNewRequest("GET", "/", map[string]string{"Accept": e.Accept}, "")
+		if e.Accept == "" {
+			req.Header.Del("Accept")
+		}
+		rsp, err := NewResponse(http.StatusOK).SetValue(req, testValue{Name: "A"})
+		if e.Error != nil {
+			assert.ErrorIs(t, err, e.Error, e.Accept)
+			continue
+		}
+		if assert.NoError(t, err, e.Accept) {
+			assert.Equal(t, e.Type, rsp.Header.Get("Content-Type"), e.Accept)
+			assert.Equal(t, "Accept", rsp.Header.Get("Vary"), e.Accept)
+			data, err := rsp.ReadEntity()
+			if assert.NoError(t, err) {
+				assert.Equal(t, e.Expect, string(data), e.Accept)
+			}
+		}
+	}
+
+	rsp := ErrNotAcceptable.Response()
+	assert.Equal(t, http.StatusNotAcceptable, rsp.Status)
+
+	_, err := NewResponse(http.StatusOK).SetValue(mustNewRequest("GET", "/", map[string]string{"Accept": "image/png"}, ""), testValue{Name: "A"})
+	var herr *HTTPError
+	if assert.ErrorAs(t, err, &herr) {
+		assert.NotSame(t, ErrNotAcceptable, herr) // decorating the error doesn't change the sentinel
+		herr.SetField("accept", "image/png")
+		assert.Nil(t, ErrNotAcceptable.Fields)
+	}
+}
@@ -1,7 +1,9 @@
 package router
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
@@ -10,12 +12,15 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	pathutil "path"
 
 	"github.com/bww/go-router/v2/path"
 )
 
+var ErrRouteNotFound = errors.New("No such route")
+
 // A route option
 type RouteOption func(*Route) *Route
 
@@ -65,36 +70,92 @@ type Context struct {
 
 // Matching state
 type matchState struct {
-	Query url.Values
+	Query  url.Values
+	Host   *string
+	Accept []qualified
+}
+
+// Obtain the request host, without a port and in lower case
+func (s *matchState) host(req *Request) string {
+	if s.Host == nil {
+		h := req.Host
+		if h == "" {
+			h = req.URL.Host
+		}
+		h = strings.TrimSuffix(strings.ToLower((&url.URL{Host: h}).Hostname()), ".")
+		s.Host = &h
+	}
+	return *s.Host
 }
 
+// The reason a route did not match a request
+type mismatch int
+
+const (
+	mismatchNone mismatch = iota
+	mismatchHost
+	mismatchMethod
+	mismatchParams
+	mismatchHeaders
+	mismatchConsumes
+	mismatchProduces
+	mismatchMatcher
+)
+
 // Candidate route matcher
 type Matcher func(*Request, *Route) bool
 
 // A matched route
 type Match struct {
+	Route  *Route // the route which matched; for mounted routers, the mounted route
 	Method string
 	Path   string
 	Params url.Values
 	Vars   path.Vars
+
+	delegate *delegate // the match in a mounted router, if any
 }
 
 // An individual route
 type Route struct {
-	handler Handler
-	middle  []Middle
-	methods map[string]struct{}
-	paths   []path.Path
-	params  url.Values
-	attrs   Attributes
-	matcher Matcher
-	once    sync.Once
+	name     string
+	handler  Handler
+	base     Handler // the handler as provided, before middleware is applied
+	middle   []Middle
+	methods  map[string]struct{}
+	paths    []path.Path
+	hosts    []path.Path
+	params   url.Values
+	headers  http.Header
+	consumes []string // media types the route consumes
+	produces []string // media types the route produces
+	attrs    Attributes
+	matcher  Matcher
+	scope    *subrouter // the subrouter the route was added through, if any
+	mount    *mount     // the mounted router or handler, if any
+	once     sync.Once
+	mu       sync.RWMutex // guards paths and changed, which may be updated once the route is added
+	changed  func()       // invoked when paths change so the router can reindex
+}
+
+// Create a route which is not yet added to a router. The route may be fully
+// configured before it is added with Router.AddRoute or Router.Replace.
+func NewRoute(p string, f Handler) *Route {
+	return &Route{
+		handler: f,
+		base:    f,
+		paths:   []path.Path{path.Parse(p)},
+	}
 }
 
 // Init finalizes a route and builds the final handler chain using the provided
 // router-level middleware. This operation is performed exactly once, usually
 // the first time a route is matched.
 //
+// If the route was added through a subrouter, the middleware of that subrouter
+// and each of its ancestors is applied between route-level and router-level
+// middleware.
+//
 // The initializaiton must be initiated by the router, since it manages the
 // router-level middleware that must be included.
 //
@@ -112,7 +173,11 @@ func (r *Route) init(m []Middle) *Route {
 				slog.With("route", r.Describe(false)).Warn("Ignoring nil middleware added to route")
 			}
 		}
-		// wrap in router-level middleware second, inside-out
+		// wrap in subrouter-level middleware second, innermost subrouter first
+		if r.scope != nil {
+			r.handler = r.scope.wrap(r.handler)
+		}
+		// wrap in router-level middleware third, inside-out
 		for i := len(m) - 1; i >= 0; i-- {
 			e := m[i]
 			if e != nil {
@@ -121,8 +186,6 @@ func (r *Route) init(m []Middle) *Route {
 				slog.With("route", r.Describe(false)).Warn("Ignoring nil middleware added to route")
 			}
 		}
-		// clear our router-level middleware after it's been added
-		r.middle = nil
 	})
 	return r
 }
@@ -155,6 +218,12 @@ func (r *Route) Use(m ...Middle) *Route {
 	return r
 }
 
+// Name sets the name of a route, which can be used to produce URLs for it
+func (r *Route) Name(n string) *Route {
+	r.name = n
+	return r
+}
+
 // Method sets the methods matched by a route
 func (r *Route) Methods(m ...string) *Route {
 	if r.methods == nil {
@@ -166,16 +235,67 @@ func (r *Route) Methods(m ...string) *Route {
 	return r
 }
 
-// Paths sets the paths matched by a route
+// Paths sets the paths matched by a route. Paths may be added to a route
+// while requests are being served; the router reindexes its routes when they
+// are.
 func (r *Route) Paths(s ...string) *Route {
-	p := make([]path.Path, len(s))
-	for i, e := range s {
-		p[i] = path.Parse(e)
+	r.mu.Lock()
+	p := make([]path.Path, len(r.paths), len(r.paths)+len(s))
+	copy(p, r.paths) // requests may be reading the current paths
+	for _, e := range s {
+		p = append(p, path.Parse(e))
+	}
+	r.paths = p
+	changed := r.changed
+	r.mu.Unlock()
+	if changed != nil {
+		changed()
 	}
-	r.paths = append(r.paths, p...)
 	return r
 }
 
+// Obtain the paths matched by a route. The result must not be modified.
+func (r *Route) pathList() []path.Path {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.paths
+}
+
+// Set the function which is invoked when the paths of a route change
+func (r *Route) setChanged(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changed = f
+}
+
+// Hosts sets the hosts matched by a route. Host templates use the same syntax
+// as paths, with '.' as the separator, and may capture variables, which are
+// merged with path variables. Hosts are matched without a port and without
+// regard to case.
+func (r *Route) Hosts(s ...string) *Route {
+	for _, e := range s {
+		r.hosts = append(r.hosts, path.ParseSeparator(lowerLiterals(e), '.'))
+	}
+	return r
+}
+
+// Convert the literal text of a template to lower case, leaving variables,
+// which may be nested in braces, unchanged
+func lowerLiterals(s string) string {
+	b := []byte(s)
+	var depth int
+	for i, e := range b {
+		if e == '{' {
+			depth++
+		} else if e == '}' && depth > 0 {
+			depth--
+		} else if depth == 0 && e >= 'A' && e <= 'Z' {
+			b[i] = e + ('a' - 'A')
+		}
+	}
+	return string(b)
+}
+
 // Param matches a single parameter
 func (r *Route) Param(k, v string) *Route {
 	if r.params == nil {
@@ -196,6 +316,51 @@ func (r *Route) Params(p url.Values) *Route {
 	return r
 }
 
+// Header matches a single header value; the request must include the value
+// among the values it provides for the header
+func (r *Route) Header(k, v string) *Route {
+	if r.headers == nil {
+		r.headers = make(http.Header)
+	}
+	r.headers.Add(k, v)
+	return r
+}
+
+// Headers matches a set of header values
+func (r *Route) Headers(h http.Header) *Route {
+	if r.headers == nil {
+		r.headers = make(http.Header)
+	}
+	for k, v := range h {
+		for _, e := range v {
+			r.headers.Add(k, e)
+		}
+	}
+	return r
+}
+
+// Consumes matches requests with a Content-Type that is one of the provided
+// media types, which may be ranges like 'text/*'. When a route doesn't match
+// a request only because of its content type, the router responds with 415
+// Unsupported Media Type.
+func (r *Route) Consumes(t ...string) *Route {
+	r.consumes = append(r.consumes, t...)
+	return r
+}
+
+// Produces matches requests which accept at least one of the provided media
+// types, according to their Accept header. Requests which do not provide an
+// Accept header accept anything. When a route doesn't match a request only
+// because of what it accepts, the router responds with 406 Not Acceptable.
+//
+// Routes are still evaluated in order, so the first route which produces an
+// acceptable media type is selected even if the client would prefer one that
+// is produced by another route.
+func (r *Route) Produces(t ...string) *Route {
+	r.produces = append(r.produces, t...)
+	return r
+}
+
 // Match via a user-provided function
 func (r *Route) Match(m Matcher) *Route {
 	r.matcher = m
@@ -222,29 +387,92 @@ func (r *Route) Attrs(attrs map[string]interface{}) *Route {
 	return r
 }
 
+// A description of how a route is configured
+type RouteInfo struct {
+	Name     string
+	Methods  []string // in upper case; empty if the route matches any method
+	Paths    []path.Path
+	Hosts    []path.Path
+	Params   url.Values
+	Headers  http.Header
+	Consumes []string
+	Produces []string
+	Attrs    Attributes
+	Mounted  Router // the router mounted by the route, if any
+}
+
+// Describe how this route is configured. The description is a copy; changing
+// it has no effect on the route.
+func (r *Route) Info() RouteInfo {
+	var methods []string
+	if len(r.methods) > 0 {
+		methods = make([]string, 0, len(r.methods))
+		for k := range r.methods {
+			methods = append(methods, strings.ToUpper(k))
+		}
+		sort.Strings(methods)
+	}
+	info := RouteInfo{
+		Name:     r.name,
+		Methods:  methods,
+		Paths:    append([]path.Path(nil), r.pathList()...),
+		Hosts:    append([]path.Path(nil), r.hosts...),
+		Params:   cloneValues(r.params),
+		Headers:  r.headers.Clone(),
+		Consumes: append([]string(nil), r.consumes...),
+		Produces: append([]string(nil), r.produces...),
+		Attrs:    r.attrs.Copy(),
+	}
+	if r.mount != nil {
+		info.Mounted = r.mount.router
+	}
+	return info
+}
+
 // Matches the provided request or not; returns the details of
 // the match if successful, otherwise nil.
 func (r *Route) Matches(req *Request, state *matchState) *Match {
-	if r.methods != nil { // if no methods specified, all methods match
-		if _, ok := r.methods[strings.ToLower(req.Method)]; !ok {
-			return nil
+	for _, e := range r.pathList() {
+		if match, vars := e.Matches(req.URL.Path); match {
+			m, _ := r.match(req, state, e, vars)
+			return m
 		}
 	}
+	return nil
+}
 
-	var (
-		match bool
-		tmpl  string
-		vars  map[string]string
-	)
-	for _, e := range r.paths {
-		match, vars = e.Matches(req.URL.Path)
-		if match {
-			tmpl = e.String()
-			break
+// Match everything except for the path, which has already been matched by
+// the caller and produced the provided vars. If the route does not match,
+// the reason is returned.
+func (r *Route) match(req *Request, state *matchState, p path.Path, vars path.Vars) (*Match, mismatch) {
+	if len(r.hosts) > 0 {
+		host := state.host(req)
+		var (
+			match bool
+			hvars path.Vars
+		)
+		for _, e := range r.hosts {
+			if match, hvars = e.Matches(host); match {
+				break
+			}
+		}
+		if !match {
+			return nil, mismatchHost
+		}
+		if len(hvars) > 0 {
+			merged := make(path.Vars, len(hvars)+len(vars))
+			for k, v := range hvars {
+				merged[k] = v
+			}
+			for k, v := range vars { // path vars take precedence
+				merged[k] = v
+			}
+			vars = merged
 		}
 	}
-	if !match {
-		return nil
+
+	if !r.acceptsMethod(req.Method) {
+		return nil, mismatchMethod
 	}
 
 	if len(r.params) > 0 {
@@ -254,26 +482,99 @@ func (r *Route) Matches(req *Request, state *matchState) *Match {
 		for k, v := range r.params {
 			c, ok := state.Query[k]
 			if !ok {
-				return nil
+				return nil, mismatchParams
 			}
 			if !reflect.DeepEqual(v, c) {
-				return nil
+				return nil, mismatchParams
+			}
+		}
+	}
+
+	for k, v := range r.headers {
+		c := req.Header.Values(k)
+		for _, e := range v {
+			if !contains(c, e) {
+				return nil, mismatchHeaders
+			}
+		}
+	}
+
+	if len(r.consumes) > 0 {
+		t := req.Header.Get("Content-Type")
+		if t == "" || !anyMediaMatches(r.consumes, t) {
+			return nil, mismatchConsumes
+		}
+	}
+
+	if len(r.produces) > 0 {
+		if a := req.Header.Values("Accept"); len(a) > 0 {
+			if state.Accept == nil {
+				state.Accept = parseQualified(strings.Join(a, ","))
+			}
+			var ok bool
+			for _, e := range r.produces {
+				if acceptQuality(state.Accept, e) > 0 {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return nil, mismatchProduces
 			}
 		}
 	}
 
 	if r.matcher != nil {
 		if !r.matcher(req, r) {
-			return nil
+			return nil, mismatchMatcher
 		}
 	}
 
 	return &Match{
+		Route:  r,
 		Method: req.Method,
-		Path:   tmpl,
+		Path:   p.String(),
 		Params: r.params,
 		Vars:   vars,
+	}, mismatchNone
+}
+
+// Does the route accept the provided method
+func (r *Route) acceptsMethod(m string) bool {
+	if r.methods == nil { // if no methods specified, all methods match
+		return true
 	}
+	_, ok := r.methods[strings.ToLower(m)]
+	return ok
+}
+
+// URL produces a URL for this route by expanding its first path with the
+// provided vars. If query parameters are provided, they are included. If the
+// route matches hosts, the first host is also expanded and included.
+func (r *Route) URL(vars path.Vars, query url.Values) (*url.URL, error) {
+	paths := r.pathList()
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("Route has no paths: %v", r)
+	}
+	p, err := paths[0].Expand(vars)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(p) // the path is already escaped
+	if err != nil {
+		return nil, err
+	}
+	if len(r.hosts) > 0 {
+		h, err := r.hosts[0].Expand(vars)
+		if err != nil {
+			return nil, err
+		}
+		u.Host = h
+	}
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u, nil
 }
 
 // Handle the request
@@ -305,14 +606,15 @@ func (r *Route) Describe(verbose bool) string {
 	b := strings.Builder{}
 	b.WriteString(methodList(r.methods))
 	b.WriteString(" ")
-	switch len(r.paths) {
+	paths := r.pathList()
+	switch len(paths) {
 	case 0:
 		b.WriteString("{}")
 	case 1:
-		b.WriteString(r.paths[0].String())
+		b.WriteString(paths[0].String())
 	default:
 		b.WriteString("{")
-		for i, e := range r.paths {
+		for i, e := range paths {
 			if i > 0 {
 				b.WriteString(", ")
 			}
@@ -324,42 +626,216 @@ func (r *Route) Describe(verbose bool) string {
 		b.WriteString(" ?")
 		b.WriteString(r.params.Encode())
 	}
+	for i, e := range r.hosts {
+		if i == 0 {
+			b.WriteString(" host:")
+		} else {
+			b.WriteString(",")
+		}
+		b.WriteString(e.String())
+	}
 	if verbose {
-		name, file, line := funcInfo(r.handler)
+		name, file, line := funcInfo(r.baseHandler())
 		b.WriteString(fmt.Sprintf(" (%s @ %s:%d)", name, file, line))
 	}
 	return b.String()
 }
 
+// Obtain the handler as it was provided, before middleware is applied. This
+// is set when the route is created and, unlike the handler, is never changed,
+// so it may be read while the route is being initialized.
+func (r *Route) baseHandler() Handler {
+	return r.base
+}
+
 // Dead simple router
 type Router interface {
+	http.Handler
 	Use(m Middle)
 	Add(p string, f Handler) *Route
 	Find(r *Request) (*Route, *Match, error)
 	Handle(r *Request) (*Response, error)
 	Subrouter(p string) Router
 	Routes() []*Route
+	URL(name string, vars path.Vars, query url.Values) (*url.URL, error)
+	Mount(p string, r Router) *Route
+	MountHandler(p string, h http.Handler) *Route
+	AddRoute(v *Route) *Route
+	Remove(v *Route) bool
+	Replace(old, v *Route) bool
+	Shadowed() []Shadow
+	Validate() error
+	Dump(w io.Writer) error
+}
+
+// A router option
+type Option func(Config) Config
+
+// Router configuration
+type Config struct {
+	// Converts an error returned by a handler into a response when the router
+	// is serving requests via net/http. If nil, DefaultErrorHandler is used.
+	ErrorHandler ErrorHandler
+	// When a request matches the path of one or more routes but none of them
+	// accept its method, respond with 405 Method Not Allowed and an Allow
+	// header instead of 404 Not Found. This is enabled by default.
+	MethodNotAllowed bool
+	// Respond to OPTIONS requests for any path matched by a route which does
+	// not itself handle OPTIONS. The response includes an Allow header listing
+	// the methods accepted by all the routes matching the path.
+	AutoOptions bool
+	// Handle HEAD requests which are not matched by any route using the route
+	// that would match the equivalent GET request. The response entity is
+	// discarded but its headers are preserved.
+	AutoHead bool
+	// The order in which routes are evaluated when matching a request. By
+	// default routes are evaluated in the order they were added.
+	Order Order
+}
+
+// The order in which routes are evaluated
+type Order int
+
+const (
+	// Routes are evaluated in the order they are added; the first route that
+	// matches a request handles it.
+	OrderAdded Order = iota
+	// Routes are evaluated from the most to the least specific path, as
+	// described by path.Path.Compare. Routes with equally specific paths are
+	// evaluated in the order they were added.
+	OrderSpecificity
+)
+
+// Set the error handler used when serving requests via net/http
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(c Config) Config {
+		c.ErrorHandler = h
+		return c
+	}
+}
+
+// Enable or disable 405 Method Not Allowed responses
+func WithMethodNotAllowed(on bool) Option {
+	return func(c Config) Config {
+		c.MethodNotAllowed = on
+		return c
+	}
+}
+
+// Enable or disable automatic OPTIONS responses
+func WithAutoOptions(on bool) Option {
+	return func(c Config) Config {
+		c.AutoOptions = on
+		return c
+	}
+}
+
+// Enable or disable automatic HEAD handling
+func WithAutoHead(on bool) Option {
+	return func(c Config) Config {
+		c.AutoHead = on
+		return c
+	}
+}
+
+// Set the order in which routes are evaluated
+func WithOrder(o Order) Option {
+	return func(c Config) Config {
+		c.Order = o
+		return c
+	}
 }
 
 type router struct {
+	config Config
+	mu     sync.Mutex // serializes changes to the route table
+	state  atomic.Pointer[snapshot]
+}
+
+// An immutable snapshot of the routes and middleware of a router. Changing
+// the router produces a new snapshot which replaces the current one, so a
+// request is handled entirely using the snapshot it started with.
+type snapshot struct {
 	routes []*Route
 	middle []Middle
+	once   sync.Once
+	index  *index
+}
+
+// Obtain the dispatch index for this snapshot, building it if necessary
+func (s *snapshot) dispatch(order Order) *index {
+	s.once.Do(func() {
+		s.index = newIndex(s.routes, order)
+	})
+	return s.index
+}
+
+// Create a new router. The default configuration is used, as modified by any
+// options provided.
+func New(opts ...Option) Router {
+	conf := Config{
+		MethodNotAllowed: true,
+	}
+	for _, opt := range opts {
+		conf = opt(conf)
+	}
+	r := &router{config: conf}
+	r.state.Store(&snapshot{})
+	return r
+}
+
+// Obtain the current snapshot of the routes and middleware
+func (r *router) load() *snapshot {
+	return r.state.Load()
 }
 
-func New() Router {
-	return &router{}
+// Change the routes or middleware. The provided function modifies a new
+// snapshot which initially shares the routes and middleware of the current
+// one and must not modify those slices in place. Appending is permitted: the
+// current snapshot never observes elements beyond its own length. Once the
+// function returns the new snapshot replaces the current one.
+func (r *router) update(f func(*snapshot)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur := r.load()
+	next := &snapshot{routes: cur.routes, middle: cur.middle}
+	f(next)
+	r.state.Store(next)
 }
 
 // Obtain a copy of all the routes managed by this router
 func (r *router) Routes() []*Route {
-	routes := make([]*Route, len(r.routes))
-	copy(routes, r.routes)
+	s := r.load()
+	routes := make([]*Route, len(s.routes))
+	copy(routes, s.routes)
 	return routes
 }
 
+// Produce a URL for the named route by expanding its path with the provided
+// vars. If no route has the name, ErrRouteNotFound is returned.
+func (r *router) URL(name string, vars path.Vars, query url.Values) (*url.URL, error) {
+	routes := r.load().routes
+	for _, e := range routes {
+		if e.name == name {
+			return e.URL(vars, query)
+		}
+	}
+	for _, e := range routes {
+		if e.mount != nil {
+			u, err := e.mount.URL(name, vars, query)
+			if err == nil {
+				return u, nil
+			} else if !errors.Is(err, ErrRouteNotFound) {
+				return nil, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrRouteNotFound, name)
+}
+
 // Derive a subrouter from this router with the specified path prefix
 func (r *router) Subrouter(p string) Router {
-	return &subrouter{r, p}
+	return &subrouter{root: r, prefix: p}
 }
 
 // Add middleware which wraps every route that is added after the middeware is
@@ -371,7 +847,9 @@ func (r *router) Subrouter(p string) Router {
 // router via this method.
 func (r *router) Use(m Middle) {
 	if m != nil {
-		r.middle = append(r.middle, m)
+		r.update(func(s *snapshot) {
+			s.middle = append(s.middle, m)
+		})
 	} else {
 		slog.Warn("Ignoring nil middleware added to router")
 	}
@@ -380,84 +858,429 @@ func (r *router) Use(m Middle) {
 // Add a route. The provided handler is the canonical, root handler. If
 // middleware is applied to the route, this handler is invoked at the end of
 // the chain (or, more accurately, the most deeply nested element).
+//
+// The route is available to requests as soon as it is added. When adding
+// routes while requests are being served, create and configure them with
+// NewRoute first and then add them with AddRoute.
 func (r *router) Add(p string, f Handler) *Route {
-	v := &Route{
-		handler: f,
-		paths:   []path.Path{path.Parse(p)},
-	}
-	r.routes = append(r.routes, v)
+	return r.add(NewRoute(p, f))
+}
+
+// Add a route which was created by NewRoute. A route may only be added to a
+// single router, once.
+func (r *router) AddRoute(v *Route) *Route {
+	return r.add(v)
+}
+
+// Add a route that has been created by the router
+func (r *router) add(v *Route) *Route {
+	v.setChanged(r.reindex)
+	r.update(func(s *snapshot) {
+		s.routes = append(s.routes, v)
+	})
 	return v
 }
 
-// Find a route for the request, if we have one
+// Remove a route. Requests which are already being handled are unaffected.
+// If the route was not added to this router, false is returned.
+func (r *router) Remove(v *Route) bool {
+	var ok bool
+	r.update(func(s *snapshot) {
+		routes := make([]*Route, 0, len(s.routes))
+		for _, e := range s.routes {
+			if e == v {
+				ok = true
+			} else {
+				routes = append(routes, e)
+			}
+		}
+		s.routes = routes
+	})
+	if ok {
+		v.setChanged(nil)
+	}
+	return ok
+}
+
+// Replace a route with another, which was created by NewRoute, in the same
+// position. The replacement is evaluated in the same order the replaced route
+// was and, unless it was added through a subrouter itself, has the same
+// subrouter middleware. Requests which are already being handled are
+// unaffected. If the replaced route was not added to this router, the
+// replacement is not added and false is returned.
+func (r *router) Replace(old, v *Route) bool {
+	var ok bool
+	r.update(func(s *snapshot) {
+		routes := make([]*Route, len(s.routes))
+		for i, e := range s.routes {
+			if e == old {
+				if v.scope == nil {
+					v.scope = old.scope
+				}
+				v.setChanged(r.reindex)
+				routes[i], ok = v, true
+			} else {
+				routes[i] = e
+			}
+		}
+		s.routes = routes
+	})
+	if ok {
+		old.setChanged(nil)
+	}
+	return ok
+}
+
+// Discard the dispatch index; it will be rebuilt the next time it is needed
+func (r *router) reindex() {
+	r.update(func(*snapshot) {})
+}
+
+// Find a route for the request, if we have one. Candidate routes are
+// obtained from the dispatch index and are then evaluated in the order
+// configured for the router; the first route that matches is selected.
 func (r *router) Find(req *Request) (*Route, *Match, error) {
+	route, match, _ := r.find(req)
+	return route, match, nil
+}
+
+// Find a route for the request. If no route matches, a description of why
+// the request was not matched is returned instead.
+func (r *router) find(req *Request) (*Route, *Match, *miss) {
+	return r.findIn(r.load(), req)
+}
+
+// Find a route for the request in the provided snapshot
+func (r *router) findIn(s *snapshot, req *Request) (*Route, *Match, *miss) {
 	state := &matchState{}
-	for _, e := range r.routes {
-		match := e.Matches(req, state)
-		if match != nil {
-			return e.init(r.middle), match, nil
+	why := &miss{}
+	for _, e := range s.dispatch(r.config.Order).find(req.URL.Path) {
+		ok, vars := e.path.Matches(req.URL.Path)
+		if !ok {
+			continue
+		}
+		match, reason := e.route.match(req, state, e.path, vars)
+		if match == nil {
+			why.add(req, e.route, e.path, vars, reason)
+			continue
 		}
+		if e.route.mount != nil {
+			var inner *miss
+			if match, inner = e.route.mount.delegate(req, match); match == nil {
+				why.merge(req, e.route, e.path, vars, inner)
+				continue
+			}
+		}
+		return e.route.init(s.middle), match, nil
 	}
-	return nil, nil, nil
+	return nil, nil, why
 }
 
 // Handle the request
 func (r *router) Handle(req *Request) (*Response, error) {
-	route, match, err := r.Find(req)
-	if err != nil {
-		return NewResponse(http.StatusInternalServerError).SetString("text/plain", fmt.Sprintf("Could not find route: %v", err))
-	} else if route == nil {
-		return NewResponse(http.StatusNotFound).SetString("text/plain", "Not found")
+	s := r.load()
+	route, match, why := r.findIn(s, req)
+	var head bool
+	if route == nil && r.config.AutoHead && req.Method == http.MethodHead {
+		route, match, _ = r.findIn(s, withMethod(req, http.MethodGet))
+		if route != nil {
+			match.Method = req.Method
+			head = true
+		}
 	}
-	var vars path.Vars
-	if match.Vars != nil {
-		vars = match.Vars
-	} else {
-		vars = make(path.Vars)
+	if route == nil {
+		if r.config.AutoOptions && req.Method == http.MethodOptions && why.route != nil {
+			return r.options(s, req, why)
+		}
+		if r.config.MethodNotAllowed && why.methodNotAllowed() {
+			return NewResponse(http.StatusMethodNotAllowed).SetHeader("Allow", r.allowed(why)).SetString("text/plain", "Method not allowed")
+		}
+		if why.unsupported {
+			return NewResponse(http.StatusUnsupportedMediaType).SetString("text/plain", "Unsupported media type")
+		}
+		if why.unacceptable {
+			return NewResponse(http.StatusNotAcceptable).SetString("text/plain", "Not acceptable")
+		}
+		return NewResponse(http.StatusNotFound).SetString("text/plain", "Not found")
 	}
-	return route.Handle(
+	rsp, err := route.Handle(
 		(*Request)((*http.Request)(req).WithContext(NewMatchContext(req.Context(), match))),
-		Context{
-			Vars:  vars,
-			Attrs: route.attrs.Copy(),
-			Path:  match.Path,
-		},
+		route.Context(match),
+	)
+	if head && rsp != nil && rsp.Entity != nil {
+		rsp.Entity.Close()
+		rsp.Entity = nil
+	}
+	return rsp, err
+}
+
+// Respond to an OPTIONS request which was not handled by any route. The
+// response is produced by a handler wrapped in subrouter- and router-level
+// middleware so that middleware may participate in the response (e.g., CORS
+// preflight).
+// The handler is invoked in the context of the first route that matched the
+// request path.
+func (r *router) options(s *snapshot, req *Request, why *miss) (*Response, error) {
+	allow := r.allowed(why)
+	var h Handler = func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusNoContent).SetHeader("Allow", allow), nil
+	}
+	if why.route.scope != nil {
+		h = why.route.scope.wrap(h)
+	}
+	for i := len(s.middle) - 1; i >= 0; i-- {
+		if e := s.middle[i]; e != nil {
+			h = e.Wrap(h)
+		}
+	}
+	return h(
+		(*Request)((*http.Request)(req).WithContext(NewMatchContext(req.Context(), why.match))),
+		why.route.Context(why.match),
 	)
 }
 
+// Produce the value of an Allow header for the methods accepted by routes
+// matching a request, including any methods the router handles automatically.
+func (r *router) allowed(why *miss) string {
+	allow := make(map[string]struct{})
+	for k := range why.allow {
+		allow[k] = struct{}{}
+	}
+	if _, ok := allow["get"]; ok && r.config.AutoHead {
+		allow["head"] = struct{}{}
+	}
+	if r.config.AutoOptions {
+		allow["options"] = struct{}{}
+	}
+	return allowList(allow)
+}
+
+// Derive a copy of a request with a different method, for matching purposes
+func withMethod(req *Request, m string) *Request {
+	c := *req
+	c.Method = m
+	return &c
+}
+
+// Describes why a request was not matched by any route. Only routes whose
+// paths matched the request are considered.
+type miss struct {
+	allow        map[string]struct{} // methods accepted by routes matching the path
+	method       bool                // a route matching the path accepted the method
+	unsupported  bool                // a route did not match only because of the content type
+	unacceptable bool                // a route did not match only because of what is accepted
+	route        *Route              // the first route matching the path
+	match        *Match              // the path match for the first route
+}
+
+// Account for a route which matched the request path but not the request.
+// Routes which do not match the request host are treated as if they did not
+// match the path.
+func (m *miss) add(req *Request, route *Route, p path.Path, vars path.Vars, reason mismatch) {
+	if reason == mismatchHost {
+		return
+	}
+	if m.route == nil {
+		m.route = route
+		m.match = &Match{
+			Route:  route,
+			Method: req.Method,
+			Path:   p.String(),
+			Vars:   vars,
+		}
+	}
+	if reason == mismatchMethod {
+		if m.allow == nil {
+			m.allow = make(map[string]struct{})
+		}
+		for k := range route.methods {
+			m.allow[k] = struct{}{}
+		}
+	} else {
+		m.method = true
+	}
+	switch reason {
+	case mismatchConsumes:
+		m.unsupported = true
+	case mismatchProduces:
+		m.unacceptable = true
+	}
+}
+
+// Account for a mount route whose mounted router did not match the request,
+// incorporating the explanation it produced
+func (m *miss) merge(req *Request, route *Route, p path.Path, vars path.Vars, inner *miss) {
+	if m.route == nil {
+		m.route = route
+		m.match = &Match{
+			Route:  route,
+			Method: req.Method,
+			Path:   p.String(),
+			Vars:   vars,
+		}
+	}
+	if inner == nil {
+		return
+	}
+	if len(inner.allow) > 0 && m.allow == nil {
+		m.allow = make(map[string]struct{})
+	}
+	for k := range inner.allow {
+		m.allow[k] = struct{}{}
+	}
+	m.method = m.method || inner.method
+	m.unsupported = m.unsupported || inner.unsupported
+	m.unacceptable = m.unacceptable || inner.unacceptable
+}
+
+// Is the request only unmatched because of its method
+func (m *miss) methodNotAllowed() bool {
+	return !m.method && len(m.allow) > 0
+}
+
 type subrouter struct {
-	parent Router
-	prefix string
+	root   *router
+	parent *subrouter // the subrouter this one was derived from, if any
+	prefix string     // the full path prefix, including that of any parents
+	middle []Middle
 }
 
-// Subrouter routes are not supported
+// Obtain the routes which were added through this subrouter or any subrouter
+// derived from it
 func (r *subrouter) Routes() []*Route {
-	return nil
+	var routes []*Route
+	for _, e := range r.root.Routes() {
+		if e.scope.within(r) {
+			routes = append(routes, e)
+		}
+	}
+	return routes
 }
 
 // Derive a subrouter from this router with the specified path prefix
 func (r *subrouter) Subrouter(p string) Router {
-	return &subrouter{r, p}
+	return &subrouter{root: r.root, parent: r, prefix: pathutil.Join(r.prefix, p)}
 }
 
-// Add middleware which wraps every route AFTER the middleware is added
+// Add middleware which wraps routes added through this subrouter. Subrouter
+// middleware is applied after route-level middleware and before the
+// middleware of any parent subrouter or the router itself, so it is nested
+// inside of those.
 func (r *subrouter) Use(m Middle) {
-	r.parent.Use(m)
+	if m != nil {
+		r.root.mu.Lock()
+		defer r.root.mu.Unlock()
+		r.middle = append(r.middle, m)
+	} else {
+		slog.Warn("Ignoring nil middleware added to subrouter")
+	}
 }
 
 // Add a route
 func (r *subrouter) Add(p string, f Handler) *Route {
-	return r.parent.Add(pathutil.Join(r.prefix, p), f)
+	return r.AddRoute(NewRoute(p, f))
+}
+
+// Add a route which was created by NewRoute. The paths of the route are
+// relative to this subrouter.
+func (r *subrouter) AddRoute(v *Route) *Route {
+	r.prefixPaths(v)
+	v.scope = r
+	return r.root.add(v)
+}
+
+// Join the paths of a route which is being added through this subrouter with
+// its prefix. The original paths are returned.
+func (r *subrouter) prefixPaths(v *Route) []path.Path {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	orig := v.paths
+	paths := make([]path.Path, len(v.paths))
+	for i, e := range v.paths {
+		paths[i] = path.Parse(pathutil.Join(r.prefix, e.String()))
+	}
+	v.paths = paths
+	return orig
+}
+
+// Remove a route
+func (r *subrouter) Remove(v *Route) bool {
+	return r.root.Remove(v)
+}
+
+// Replace a route with another in the same position. Like the paths of routes
+// added through this subrouter, the paths of the replacement are relative to
+// it.
+func (r *subrouter) Replace(old, v *Route) bool {
+	orig := r.prefixPaths(v)
+	if !r.root.Replace(old, v) {
+		v.mu.Lock()
+		v.paths = orig // the route wasn't added
+		v.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// Is the receiver the provided subrouter or derived from it
+func (r *subrouter) within(s *subrouter) bool {
+	for e := r; e != nil; e = e.parent {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap a handler in the middleware of this subrouter and its parents,
+// inside-out. The middleware is wrapped without holding the router's lock,
+// since it may use the router.
+func (r *subrouter) wrap(h Handler) Handler {
+	m := r.middleware()
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i].Wrap(h)
+	}
+	return h
+}
+
+// The middleware applied by this subrouter and its ancestors, outermost first
+func (r *subrouter) middleware() []Middle {
+	if r == nil {
+		return nil
+	}
+	r.root.mu.Lock()
+	defer r.root.mu.Unlock()
+	var res []Middle
+	for s := r; s != nil; s = s.parent {
+		res = append(append([]Middle(nil), s.middle...), res...)
+	}
+	return res
+}
+
+// Produce a URL for the named route. Routes added through a subrouter include
+// the subrouter's prefix.
+func (r *subrouter) URL(name string, vars path.Vars, query url.Values) (*url.URL, error) {
+	return r.root.URL(name, vars, query)
 }
 
 // Find a route for the request, if we have one
-func (r subrouter) Find(req *Request) (*Route, *Match, error) {
-	return r.parent.Find(req)
+func (r *subrouter) Find(req *Request) (*Route, *Match, error) {
+	return r.root.Find(req)
+}
+
+// Find a route for the request or explain why there isn't one
+func (r *subrouter) find(req *Request) (*Route, *Match, *miss) {
+	return r.root.find(req)
 }
 
 // Handle the request
-func (r subrouter) Handle(req *Request) (*Response, error) {
-	return r.parent.Handle(req)
+func (r *subrouter) Handle(req *Request) (*Response, error) {
+	return r.root.Handle(req)
+}
+
+// Serve an HTTP request
+func (r *subrouter) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
+	r.root.ServeHTTP(rsp, req)
 }
 
 // List of set methods
@@ -478,9 +1301,51 @@ func methodList(m map[string]struct{}) string {
 	}
 }
 
+// Does the set contain the value
+func contains(set []string, v string) bool {
+	for _, e := range set {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Does the media type match any of the provided media ranges
+func anyMediaMatches(ranges []string, t string) bool {
+	for _, e := range ranges {
+		if mediaMatches(e, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow header value for the set methods
+func allowList(m map[string]struct{}) string {
+	n := make([]string, 0, len(m))
+	for k := range m {
+		n = append(n, strings.ToUpper(k))
+	}
+	sort.Strings(n)
+	return strings.Join(n, ", ")
+}
+
 func funcInfo(v any) (string, string, int) {
 	p := reflect.ValueOf(v).Pointer()
 	f := runtime.FuncForPC(p)
 	file, line := f.FileLine(p)
 	return f.Name(), file, line
 }
+
+// Deep copy query values; nil values remain nil
+func cloneValues(v url.Values) url.Values {
+	if v == nil {
+		return nil
+	}
+	c := make(url.Values, len(v))
+	for k, e := range v {
+		c[k] = append([]string(nil), e...)
+	}
+	return c
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -294,6 +295,69 @@ func BenchmarkRoutes(b *testing.B) {
 
 }
 
+func TestRoutesOrder(t *testing.T) {
+	handler := func(v string) Handler {
+		return func(*Request, Context) (*Response, error) {
+			return NewResponse(http.StatusOK).SetString("text/plain", v)
+		}
+	}
+
+	r := New()
+	r.Add("/a/**", handler("A")).Methods("POST")
+	r.Add("/a/{var}", handler("B")).Methods("GET")
+	r.Add("/a/b", handler("C"))
+	r.Add("/a/b", handler("D")).Methods("PUT")
+	r.Add("/a/**", handler("E"))
+	x := r.Add("/x", handler("F"))
+	x.Paths("/q/c/d") // paths added after the route is registered are indexed
+
+	req, err := NewRequest("GET", "/a/b", nil)
+	if assert.NoError(t, err) {
+		checkRoute(t, r, req, "/a/{var}", path.Vars{"var": "b"}, []byte("B"), nil)
+	}
+	req, err = NewRequest("PUT", "/a/b", nil)
+	if assert.NoError(t, err) {
+		checkRoute(t, r, req, "/a/b", nil, []byte("C"), nil)
+	}
+	req, err = NewRequest("POST", "/a/b/c", nil)
+	if assert.NoError(t, err) {
+		checkRoute(t, r, req, "/a/**", nil, []byte("A"), nil)
+	}
+	req, err = NewRequest("GET", "/q/c/d", nil)
+	if assert.NoError(t, err) {
+		checkRoute(t, r, req, "/q/c/d", nil, []byte("F"), nil)
+	}
+	req, err = NewRequest("GET", "/a/b/c", nil)
+	if assert.NoError(t, err) {
+		checkRoute(t, r, req, "/a/**", nil, []byte("E"), nil)
+	}
+}
+
+func BenchmarkManyRoutes(b *testing.B) {
+	funcA := func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "A")
+	}
+
+	r := New()
+	for i := 0; i < 500; i++ {
+		r.Add(fmt.Sprintf("/r%d/{id}", i), funcA).Methods("GET")
+	}
+
+	req, err := NewRequest("GET", "/r499/123", nil)
+	if err != nil {
+		panic(err)
+	}
+	for n := 0; n < b.N; n++ {
+		x, _, err := r.Find(req)
+		if err != nil {
+			panic(err)
+		}
+		if x == nil {
+			panic(fmt.Errorf("Could not route: %v", req))
+		}
+	}
+}
+
 func TestRouteAttrs(t *testing.T) {
 	var req *Request
 	var err error
@@ -369,3 +433,473 @@ func TestMiddleware(t *testing.T) {
 		handleRoute(t, r, req, http.StatusOK, []byte(fmt.Sprintf("%s: key=val", req.URL.Path)), nil)
 	}
 }
+
+func TestMethodNotAllowed(t *testing.T) {
+	funcA := func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "A")
+	}
+
+	r := New()
+	r.Add("/a", funcA).Methods("GET", "PUT")
+	r.Add("/a", funcA).Methods("POST")
+	r.Add("/b", funcA).Methods("GET").Param("foo", "bar")
+
+	req, err := NewRequest("DELETE", "/a", nil)
+	if assert.NoError(t, err) {
+		rsp, err := r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusMethodNotAllowed, rsp.Status)
+			assert.Equal(t, "GET, POST, PUT", rsp.Header.Get("Allow"))
+		}
+	}
+	req, err = NewRequest("GET", "/b", nil) // method is fine, params don't match
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusNotFound, []byte("Not found"), nil)
+	}
+	req, err = NewRequest("GET", "/c", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusNotFound, []byte("Not found"), nil)
+	}
+
+	r = New(WithMethodNotAllowed(false))
+	r.Add("/a", funcA).Methods("GET")
+	req, err = NewRequest("DELETE", "/a", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusNotFound, []byte("Not found"), nil)
+	}
+}
+
+func TestAutoOptionsHead(t *testing.T) {
+	funcA := func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetHeader("X-Route", "A").SetString("text/plain", "A")
+	}
+
+	var middle int
+	r := New(WithAutoOptions(true), WithAutoHead(true))
+	r.Use(MiddleFunc(func(h Handler) Handler {
+		return func(req *Request, cxt Context) (*Response, error) {
+			middle++
+			return h(req, cxt)
+		}
+	}))
+	r.Add("/a/{id}", funcA).Methods("GET")
+	r.Add("/a/{id}", funcA).Methods("PUT", "DELETE")
+
+	req, err := NewRequest("OPTIONS", "/a/1", nil)
+	if assert.NoError(t, err) {
+		rsp, err := r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNoContent, rsp.Status)
+			assert.Equal(t, "DELETE, GET, HEAD, OPTIONS, PUT", rsp.Header.Get("Allow"))
+			assert.Equal(t, 1, middle)
+		}
+	}
+	req, err = NewRequest("OPTIONS", "/b", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusNotFound, []byte("Not found"), nil)
+	}
+
+	req, err = NewRequest("HEAD", "/a/1", nil)
+	if assert.NoError(t, err) {
+		rsp, err := r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rsp.Status)
+			assert.Equal(t, "A", rsp.Header.Get("X-Route"))
+			assert.Equal(t, "text/plain", rsp.Header.Get("Content-Type"))
+			assert.Nil(t, rsp.Entity)
+		}
+	}
+
+	r = New()
+	r.Add("/a", funcA).Methods("GET")
+	req, err = NewRequest("OPTIONS", "/a", nil)
+	if assert.NoError(t, err) {
+		rsp, err := r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusMethodNotAllowed, rsp.Status)
+			assert.Equal(t, "GET", rsp.Header.Get("Allow"))
+		}
+	}
+}
+
+func TestRouteConstraints(t *testing.T) {
+	handler := func(v string) Handler {
+		return func(*Request, Context) (*Response, error) {
+			return NewResponse(http.StatusOK).SetString("text/plain", v)
+		}
+	}
+
+	r := New()
+	r.Add("/users/{id:int}", handler("A")).Methods("GET")
+	r.Add("/users/{name}", handler("B")).Methods("GET")
+
+	req, err := NewRequest("GET", "/users/123", nil)
+	if assert.NoError(t, err) {
+		checkRoute(t, r, req, "/users/{id:int}", path.Vars{"id": "123"}, []byte("A"), nil)
+	}
+	req, err = NewRequest("GET", "/users/me", nil)
+	if assert.NoError(t, err) {
+		checkRoute(t, r, req, "/users/{name}", path.Vars{"name": "me"}, []byte("B"), nil)
+	}
+}
+
+func TestRouteURL(t *testing.T) {
+	funcA := func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "A")
+	}
+
+	r := New()
+	r.Add("/users/{id:int}", funcA).Methods("GET").Name("user")
+	s := r.Subrouter("/files")
+	s.Add("/{path...}", funcA).Methods("GET").Name("file")
+
+	u, err := r.URL("user", path.Vars{"id": "123"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/users/123", u.String())
+	}
+	u, err = r.URL("user", path.Vars{"id": "123"}, url.Values{"expand": {"true"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/users/123?expand=true", u.String())
+	}
+	u, err = s.URL("file", path.Vars{"path": "a/b c.txt"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/files/a/b%20c.txt", u.String())
+	}
+
+	_, err = r.URL("user", path.Vars{}, nil)
+	assert.ErrorIs(t, err, path.ErrMissingVar)
+	_, err = r.URL("user", path.Vars{"id": "me"}, nil)
+	assert.ErrorIs(t, err, path.ErrInvalidVar)
+	_, err = r.URL("nope", nil, nil)
+	assert.ErrorIs(t, err, ErrRouteNotFound)
+}
+
+func TestSubrouterMiddleware(t *testing.T) {
+	handler := func(req *Request, cxt Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "H")
+	}
+	tag := func(v string) Middle {
+		return MiddleFunc(func(h Handler) Handler {
+			return func(req *Request, cxt Context) (*Response, error) {
+				rsp, err := h(req, cxt)
+				if err != nil {
+					return nil, err
+				}
+				return NewResponse(http.StatusOK).SetString("text/plain", v+"("+string(errors.Must(io.ReadAll(rsp.Entity)))+")")
+			}
+		})
+	}
+
+	r := New()
+	r.Use(tag("R"))
+	a := r.Subrouter("/a")
+	a.Use(tag("A"))
+	b := a.Subrouter("/b")
+	b.Use(tag("B"))
+
+	r.Add("/x", handler)
+	a.Add("/x", handler)
+	b.Add("/x", handler).Use(tag("X"))
+
+	req, err := NewRequest("GET", "/x", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("R(H)"), nil)
+	}
+	req, err = NewRequest("GET", "/a/x", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("R(A(H))"), nil)
+	}
+	req, err = NewRequest("GET", "/a/b/x", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("R(A(B(X(H))))"), nil)
+	}
+
+	assert.Len(t, r.Routes(), 3)
+	if routes := a.Routes(); assert.Len(t, routes, 2) {
+		assert.Equal(t, "* /a/x", routes[0].String())
+		assert.Equal(t, "* /a/b/x", routes[1].String())
+	}
+	if routes := b.Routes(); assert.Len(t, routes, 1) {
+		assert.Equal(t, "* /a/b/x", routes[0].String())
+	}
+
+	// middleware may use the router when it wraps a handler
+	c := r.Subrouter("/c")
+	c.Use(MiddleFunc(func(h Handler) Handler {
+		c.Add("/wrapped", handler)
+		return h
+	}))
+	c.Add("/x", handler)
+	req, err = NewRequest("GET", "/c/x", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("R(H)"), nil)
+	}
+	assert.Len(t, c.Routes(), 2)
+}
+
+func TestRouteSpecificity(t *testing.T) {
+	handler := func(v string) Handler {
+		return func(*Request, Context) (*Response, error) {
+			return NewResponse(http.StatusOK).SetString("text/plain", v)
+		}
+	}
+
+	r := New(WithOrder(OrderSpecificity))
+	r.Add("/users/**", handler("A")).Methods("GET")
+	r.Add("/users/*", handler("B")).Methods("GET")
+	r.Add("/users/{id}", handler("C")).Methods("GET")
+	r.Add("/users/{id:int}", handler("D")).Methods("GET")
+	r.Add("/users/me", handler("E")).Methods("GET")
+
+	tests := []struct {
+		Path   string
+		Tmpl   string
+		Vars   path.Vars
+		Expect string
+	}{
+		{"/users/me", "/users/me", nil, "E"},
+		{"/users/123", "/users/{id:int}", path.Vars{"id": "123"}, "D"},
+		{"/users/abc", "/users/{id}", path.Vars{"id": "abc"}, "C"},
+		{"/users/abc/def", "/users/**", nil, "A"},
+	}
+	for _, e := range tests {
+		req, err := NewRequest("GET", e.Path, nil)
+		if assert.NoError(t, err) {
+			checkRoute(t, r, req, e.Tmpl, e.Vars, []byte(e.Expect), nil)
+		}
+	}
+
+	shadowed := r.Shadowed()
+	if assert.Len(t, shadowed, 1) {
+		assert.Equal(t, "GET /users/*", shadowed[0].Route.String())
+		assert.Equal(t, "GET /users/{id}", shadowed[0].By.String())
+	}
+}
+
+func TestRouteShadowed(t *testing.T) {
+	funcA := func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "A")
+	}
+
+	r := New()
+	r.Add("/users/{id}", funcA).Methods("GET", "PUT")
+	r.Add("/users/me", funcA).Methods("GET")                   // shadowed
+	r.Add("/users/me", funcA).Methods("DELETE")                // reachable, different method
+	r.Add("/users/{id}", funcA).Methods("GET").Param("a", "b") // shadowed, params are more restrictive
+	r.Add("/files/{id}", funcA).Match(func(*Request, *Route) bool { return true })
+	r.Add("/files/x", funcA)                                     // reachable, matchers never shadow
+	r.Add("/other", funcA).Methods("GET").Paths("/users/{name}") // reachable, via /other
+
+	shadowed := r.Shadowed()
+	if assert.Len(t, shadowed, 2) {
+		assert.Equal(t, "GET /users/me", shadowed[0].Route.String())
+		assert.Equal(t, "{GET, PUT} /users/{id}", shadowed[0].By.String())
+		assert.Equal(t, "GET /users/{id} ?a=b", shadowed[1].Route.String())
+	}
+}
+
+func TestRouteValidate(t *testing.T) {
+	funcA := func(*Request, Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "A")
+	}
+
+	r := New()
+	r.Add("/a/{x}", funcA).Methods("GET")
+	r.Add("/a/{y}", funcA).Methods("GET") // duplicate
+	r.Add("/a/b", funcA).Methods("GET")   // shadowed
+	r.Add("/c", funcA).Methods("GET", "POST")
+	r.Add("/c", funcA).Methods("POST", "PUT", "DELETE")                 // overlaps for POST
+	r.Add("/d", funcA)                                                  // no methods
+	r.(*router).add(&Route{handler: funcA, base: funcA}).Methods("GET") // no paths
+	r.Add("/a/{x}", funcA).Methods("POST")                              // fine
+	r.Mount("/f", New())                                                // mounts match any method
+
+	err := r.Validate()
+	var verr ValidationError
+	if assert.ErrorAs(t, err, &verr) && assert.Len(t, verr, 5) {
+		assert.Equal(t, Conflict{Kind: ConflictDuplicate, Route: r.Routes()[1], Other: r.Routes()[0]}, verr[0])
+		assert.Equal(t, Conflict{Kind: ConflictShadowed, Route: r.Routes()[2], Other: r.Routes()[0]}, verr[1])
+		assert.Equal(t, Conflict{Kind: ConflictOverlap, Route: r.Routes()[4], Other: r.Routes()[3], Methods: []string{"POST"}}, verr[2])
+		assert.Equal(t, ConflictNoMethods, verr[3].Kind)
+		assert.Equal(t, ConflictNoPaths, verr[4].Kind)
+		assert.Equal(t, "GET /a/{y} duplicates GET /a/{x}", verr[0].String())
+		assert.Equal(t, "{DELETE, POST, PUT} /c is shadowed by {GET, POST} /c for POST", verr[2].String())
+		assert.Contains(t, err.Error(), "* /d has no methods")
+	}
+
+	s := r.Subrouter("/s")
+	s.Add("/a", funcA).Methods("GET")
+	assert.NoError(t, s.Validate())
+	s.Add("/a", funcA).Methods("GET")
+	if assert.ErrorAs(t, s.Validate(), &verr) {
+		assert.Len(t, verr, 1)
+	}
+
+	r = New()
+	r.Add("/a", funcA).Methods("GET")
+	r.Add("/b", funcA).Methods("GET")
+	assert.NoError(t, r.Validate())
+}
+
+func TestRouteUpdates(t *testing.T) {
+	handler := func(v string) Handler {
+		return func(*Request, Context) (*Response, error) {
+			return NewResponse(http.StatusOK).SetString("text/plain", v)
+		}
+	}
+
+	r := New()
+	a := r.Add("/a", handler("A")).Methods("GET")
+	r.Add("/{x}", handler("X")).Methods("GET")
+
+	req, err := NewRequest("GET", "/a", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	handleRoute(t, r, req, http.StatusOK, []byte("A"), nil)
+
+	b := NewRoute("/a", handler("B")).Methods("GET")
+	assert.True(t, r.Replace(a, b))
+	assert.False(t, r.Replace(a, b))
+	handleRoute(t, r, req, http.StatusOK, []byte("B"), nil) // evaluated in the position of the original
+
+	assert.True(t, r.Remove(b))
+	assert.False(t, r.Remove(b))
+	handleRoute(t, r, req, http.StatusOK, []byte("X"), nil)
+
+	s := r.Subrouter("/s")
+	s.Use(MiddleFunc(func(h Handler) Handler {
+		return func(req *Request, cxt Context) (*Response, error) {
+			return NewResponse(http.StatusOK).SetString("text/plain", "S")
+		}
+	}))
+	c := s.AddRoute(NewRoute("/c", handler("C")).Methods("GET"))
+	assert.Equal(t, "GET /s/c", c.String())
+	req, err = NewRequest("GET", "/s/c", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("S"), nil)
+	}
+
+	d := NewRoute("/d", handler("D")).Methods("GET")
+	assert.False(t, s.Replace(a, d))
+	assert.Equal(t, "GET /d", d.String())
+	assert.True(t, s.Replace(c, d))
+	assert.Equal(t, "GET /s/d", d.String()) // paths are relative to the subrouter
+	req, err = NewRequest("GET", "/s/d", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("S"), nil) // the replacement is in the subrouter's scope
+	}
+	req, err = NewRequest("GET", "/d", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("X"), nil)
+	}
+
+	// change routes while requests are being handled; this is only
+	// meaningful when tests are run with the race detector
+	live := r.Add("/live", handler("L")).Methods("GET")
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := NewRequest("GET", "/live", nil)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					rsp, err := r.Handle(req)
+					if assert.NoError(t, err) && rsp.Entity != nil {
+						rsp.Entity.Close()
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		v := r.AddRoute(NewRoute("/live", handler("L")).Methods("GET"))
+		r.Use(MiddleFunc(func(h Handler) Handler { return h }))
+		r.Remove(v)
+		live.Paths(fmt.Sprintf("/live/%d", i))
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestRouteHosts(t *testing.T) {
+	handler := func(req *Request, cxt Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", fmt.Sprintf("%s/%s", cxt.Vars["tenant"], cxt.Vars["id"]))
+	}
+	other := func(req *Request, cxt Context) (*Response, error) {
+		return NewResponse(http.StatusOK).SetString("text/plain", "other")
+	}
+
+	r := New()
+	r.Add("/users/{id}", handler).Methods("GET").Hosts("{tenant}.API.Example.com").Name("user")
+	r.Add("/users/{id}", other).Methods("POST")
+
+	req, err := NewRequest("GET", "http://acme.api.example.com:8080/users/123", nil)
+	if assert.NoError(t, err) {
+		_, match, err := r.Find(req)
+		if assert.NoError(t, err) && assert.NotNil(t, match) {
+			assert.Equal(t, path.Vars{"tenant": "acme", "id": "123"}, match.Vars)
+		}
+		handleRoute(t, r, req, http.StatusOK, []byte("acme/123"), nil)
+	}
+	req, err = NewRequest("GET", "http://ACME.API.example.com/users/123", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusOK, []byte("acme/123"), nil)
+	}
+	req, err = NewRequest("GET", "http://acme.example.com/users/123", nil)
+	if assert.NoError(t, err) {
+		rsp, err := r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusMethodNotAllowed, rsp.Status)
+			assert.Equal(t, "POST", rsp.Header.Get("Allow")) // the host-only route is not considered
+		}
+	}
+
+	u, err := r.URL("user", path.Vars{"tenant": "acme", "id": "123"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "//acme.api.example.com/users/123", u.String())
+	}
+}
+
+func TestRouteHeaders(t *testing.T) {
+	handler := func(v string) Handler {
+		return func(*Request, Context) (*Response, error) {
+			return NewResponse(http.StatusOK).SetString("text/plain", v)
+		}
+	}
+
+	r := New()
+	r.Add("/a", handler("A")).Methods("POST").Header("X-Version", "2")
+	r.Add("/a", handler("B")).Methods("POST").Consumes("application/json")
+	r.Add("/a", handler("C")).Methods("POST").Consumes("text/*")
+	r.Add("/b", handler("D")).Methods("GET").Produces("application/json")
+	r.Add("/b", handler("E")).Methods("GET").Produces("text/csv")
+
+	tests := []struct {
+		Method string
+		Path   string
+		Header map[string]string
+		Status int
+		Expect string
+	}{
+		{"POST", "/a", map[string]string{"X-Version": "2"}, http.StatusOK, "A"},
+		{"POST", "/a", map[string]string{"Content-Type": "application/json; charset=utf-8"}, http.StatusOK, "B"},
+		{"POST", "/a", map[string]string{"Content-Type": "text/plain"}, http.StatusOK, "C"},
+		{"POST", "/a", map[string]string{"Content-Type": "image/png"}, http.StatusUnsupportedMediaType, "Unsupported media type"},
+		{"POST", "/a", nil, http.StatusUnsupportedMediaType, "Unsupported media type"},
+		{"GET", "/b", nil, http.StatusOK, "D"},
+		{"GET", "/b", map[string]string{"Accept": "application/json"}, http.StatusOK, "D"},
+		{"GET", "/b", map[string]string{"Accept": "text/*"}, http.StatusOK, "E"},
+		{"GET", "/b", map[string]string{"Accept": "application/json;q=0, */*"}, http.StatusOK, "E"},
+		{"GET", "/b", map[string]string{"Accept": "image/png"}, http.StatusNotAcceptable, "Not acceptable"},
+	}
+	for _, e := range tests {
+		req := mustNewRequest(e.Method, e.Path, e.Header, "")
+		handleRoute(t, r, req, e.Status, []byte(e.Expect), nil)
+	}
+}
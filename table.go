@@ -0,0 +1,207 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	pathutil "path"
+)
+
+// A row in the route table, which describes a route as it is served
+type routeEntry struct {
+	Name       string            `json:"name,omitempty"`
+	Methods    []string          `json:"methods,omitempty"`
+	Paths      []string          `json:"paths"`
+	Hosts      []string          `json:"hosts,omitempty"`
+	Params     url.Values        `json:"params,omitempty"`
+	Headers    http.Header       `json:"headers,omitempty"`
+	Consumes   []string          `json:"consumes,omitempty"`
+	Produces   []string          `json:"produces,omitempty"`
+	Handler    string            `json:"handler"`
+	Source     string            `json:"source,omitempty"`
+	Middleware []string          `json:"middleware,omitempty"`
+	Attrs      map[string]string `json:"attrs,omitempty"`
+}
+
+// Produce the route table for a router. Routes of mounted routers are listed
+// in place of the routes which mount them, with their full paths and the
+// middleware applied by both routers, outermost first.
+func routeTable(r Router) []routeEntry {
+	var outer []Middle
+	switch v := r.(type) {
+	case *router:
+		outer = v.load().middle
+	case *subrouter:
+		outer = v.root.load().middle
+	}
+	var res []routeEntry
+	for _, e := range r.Routes() {
+		middle := append(append([]Middle(nil), outer...), e.scope.middleware()...)
+		middle = append(middle, e.middle...)
+		names := make([]string, 0, len(middle))
+		for _, m := range middle {
+			if m != nil {
+				names = append(names, middleName(m))
+			}
+		}
+
+		if e.mount != nil && e.mount.router != nil {
+			prefix := e.mount.prefix.String()
+			for _, sub := range routeTable(e.mount.router) {
+				for i, p := range sub.Paths {
+					sub.Paths[i] = pathutil.Join(prefix, p)
+				}
+				sub.Middleware = append(append([]string(nil), names...), sub.Middleware...)
+				res = append(res, sub)
+			}
+			continue
+		}
+
+		info := e.Info()
+		entry := routeEntry{
+			Name:       info.Name,
+			Methods:    info.Methods,
+			Params:     info.Params,
+			Consumes:   info.Consumes,
+			Produces:   info.Produces,
+			Middleware: names,
+		}
+		if len(info.Headers) > 0 {
+			entry.Headers = info.Headers
+		}
+		for _, p := range info.Paths {
+			entry.Paths = append(entry.Paths, p.String())
+		}
+		for _, h := range info.Hosts {
+			entry.Hosts = append(entry.Hosts, h.String())
+		}
+		if len(info.Attrs) > 0 {
+			entry.Attrs = make(map[string]string, len(info.Attrs))
+			for k, v := range info.Attrs {
+				entry.Attrs[k] = fmt.Sprint(v)
+			}
+		}
+		if e.mount != nil {
+			entry.Handler = fmt.Sprintf("%T", e.mount.handler)
+		} else if h := e.baseHandler(); h != nil {
+			name, file, line := funcInfo(h)
+			entry.Handler = name
+			entry.Source = fmt.Sprintf("%s:%d", file, line)
+		}
+		res = append(res, entry)
+	}
+	return res
+}
+
+// Describe middleware. Middleware which implements fmt.Stringer describes
+// itself, middleware functions are described by their names, and anything
+// else is described by its type.
+func middleName(m Middle) string {
+	switch v := m.(type) {
+	case fmt.Stringer:
+		return v.String()
+	case MiddleFunc:
+		name, _, _ := funcInfo(v)
+		return name
+	case Middles:
+		n := make([]string, len(v))
+		for i, e := range v {
+			n[i] = middleName(e)
+		}
+		return "[" + strings.Join(n, ", ") + "]"
+	default:
+		return fmt.Sprintf("%T", m)
+	}
+}
+
+// Write the route table as aligned text, one route per line
+func writeTable(w io.Writer, table []routeEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHODS\tPATH\tNAME\tHANDLER\tMIDDLEWARE\tATTRS")
+	for _, e := range table {
+		methods := "*"
+		if len(e.Methods) > 0 {
+			methods = strings.Join(e.Methods, ",")
+		}
+		paths := strings.Join(e.Paths, ",")
+		if len(e.Hosts) > 0 {
+			paths += " host:" + strings.Join(e.Hosts, ",")
+		}
+		if len(e.Params) > 0 {
+			paths += " ?" + e.Params.Encode()
+		}
+		handler := e.Handler
+		if e.Source != "" {
+			handler += " (" + e.Source + ")"
+		}
+		attrs := make([]string, 0, len(e.Attrs))
+		for k, v := range e.Attrs {
+			attrs = append(attrs, k+"="+v)
+		}
+		sort.Strings(attrs)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", methods, paths, orDash(e.Name), orDash(handler), orDash(strings.Join(e.Middleware, ",")), orDash(strings.Join(attrs, " ")))
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Dump writes the route table as text to the provided writer. The table lists
+// every route with its handler, the middleware applied to it, and its
+// attributes; it's intended for startup logs and debugging.
+func (r *router) Dump(w io.Writer) error {
+	return writeTable(w, routeTable(r))
+}
+
+// Dump writes the table of routes added through this subrouter
+func (r *subrouter) Dump(w io.Writer) error {
+	return writeTable(w, routeTable(r))
+}
+
+// TableHandler produces a handler which renders the route table of a router.
+// The table is rendered as JSON when the request prefers 'application/json'
+// and as text otherwise. Since the table describes the internals of a service,
+// access to this handler should usually be restricted.
+func TableHandler(r Router) Handler {
+	return func(req *Request, cxt Context) (*Response, error) {
+		t := "text/plain"
+		if a := req.Header.Values("Accept"); len(a) > 0 {
+			ranges := parseQualified(strings.Join(a, ","))
+			var best float64
+			t = ""
+			for _, e := range []string{"text/plain", "application/json"} {
+				if q := acceptQuality(ranges, e); q > best {
+					t, best = e, q
+				}
+			}
+			if t == "" {
+				return nil, errNotAcceptable()
+			}
+		}
+		rsp := NewResponse(http.StatusOK).SetHeader("Vary", "Accept")
+		table := routeTable(r)
+		if t == "application/json" {
+			data, err := json.Marshal(table)
+			if err != nil {
+				return nil, err
+			}
+			return rsp.SetBytes("application/json", data)
+		}
+		b := &strings.Builder{}
+		if err := writeTable(b, table); err != nil {
+			return nil, err
+		}
+		return rsp.SetString("text/plain; charset=utf-8", b.String())
+	}
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namedMiddle string
+
+func (m namedMiddle) Wrap(h Handler) Handler {
+	return h
+}
+
+func (m namedMiddle) String() string {
+	return string(m)
+}
+
+func tableHandler(*Request, Context) (*Response, error) {
+	return NewResponse(http.StatusOK), nil
+}
+
+func TestRouteTable(t *testing.T) {
+	inner := New()
+	inner.Use(namedMiddle("inner"))
+	inner.Add("/users/{id}", tableHandler).Methods("GET").Name("user")
+
+	r := New()
+	r.Use(namedMiddle("outer"))
+	r.Add("/a", tableHandler).Methods("POST", "GET").Attr("role", "admin").Use(namedMiddle("route"))
+	s := r.Subrouter("/s")
+	s.Use(namedMiddle("sub"))
+	s.Add("/b", tableHandler)
+	r.Mount("/api", inner)
+
+	// middleware must still be described once routes have been initialized
+	req, err := NewRequest("GET", "/a", nil)
+	if assert.NoError(t, err) {
+		_, err = r.Handle(req)
+		assert.NoError(t, err)
+	}
+
+	table := routeTable(r)
+	if assert.Len(t, table, 3) {
+		assert.Equal(t, []string{"GET", "POST"}, table[0].Methods)
+		assert.Equal(t, []string{"/a"}, table[0].Paths)
+		assert.Equal(t, []string{"outer", "route"}, table[0].Middleware)
+		assert.Equal(t, map[string]string{"role": "admin"}, table[0].Attrs)
+		assert.True(t, strings.HasSuffix(table[0].Handler, "tableHandler"), table[0].Handler)
+		assert.Contains(t, table[0].Source, "table_test.go:")
+
+		assert.Equal(t, []string{"/s/b"}, table[1].Paths)
+		assert.Equal(t, []string{"outer", "sub"}, table[1].Middleware)
+
+		assert.Equal(t, "user", table[2].Name)
+		assert.Equal(t, []string{"/api/users/{id}"}, table[2].Paths)
+		assert.Equal(t, []string{"outer", "inner"}, table[2].Middleware)
+	}
+
+	b := &strings.Builder{}
+	if assert.NoError(t, r.Dump(b)) {
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		if assert.Len(t, lines, 4) {
+			assert.True(t, strings.HasPrefix(lines[0], "METHODS"))
+			assert.Contains(t, lines[1], "GET,POST")
+			assert.Contains(t, lines[1], "role=admin")
+			assert.True(t, strings.HasPrefix(lines[2], "*"))
+			assert.Contains(t, lines[3], "/api/users/{id}")
+		}
+	}
+
+	b = &strings.Builder{}
+	if assert.NoError(t, s.Dump(b)) {
+		assert.Len(t, strings.Split(strings.TrimSpace(b.String()), "\n"), 2)
+	}
+
+	r.Add("/routes", TableHandler(r)).Methods("GET")
+	req, err = NewRequest("GET", "/routes", nil)
+	if assert.NoError(t, err) {
+		req.Header.Set("Accept", "application/json")
+		rsp, err := r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "application/json", rsp.Header.Get("Content-Type"))
+			var v []routeEntry
+			data, err := rsp.ReadEntity()
+			if assert.NoError(t, err) && assert.NoError(t, json.Unmarshal(data, &v)) {
+				assert.Len(t, v, 4)
+			}
+		}
+		req.Header.Set("Accept", "text/*")
+		rsp, err = r.Handle(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "text/plain; charset=utf-8", rsp.Header.Get("Content-Type"))
+		}
+		req.Header.Set("Accept", "image/png")
+		_, err = r.Handle(req)
+		assert.ErrorIs(t, err, ErrNotAcceptable)
+	}
+}
+
+func TestRouteTableConcurrent(t *testing.T) {
+	r := New()
+	r.Use(namedMiddle("Outer"))
+	for _, p := range []string{"/a", "/b", "/c", "/d"} {
+		r.Add(p, tableHandler).Methods("GET")
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range []string{"/a", "/b", "/c", "/d"} {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			req, err := NewRequest("GET", p, nil)
+			if assert.NoError(t, err) {
+				_, err = r.Handle(req)
+				assert.NoError(t, err)
+			}
+		}(p)
+	}
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, r.Dump(io.Discard))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"github.com/bww/go-router/v2/entity"
+)
+
+// Typed adapts a function which accepts and produces values into a Handler.
+//
+// The input is populated from the request: if the request has an entity it is
+// decoded into the input as described by Request.Decode, after which fields
+// tagged with 'path', 'query' and 'header' are bound to path variables, query
+// parameters and headers, respectively. Values bound from the request take
+// precedence over those decoded from the entity. The input may be a struct or
+// a pointer to a struct; other types are only decoded from the entity. If the
+// input cannot be populated a 400 error is produced.
+//
+// If the output is a *Response it is returned as-is, if it is a Responder its
+// response is used, and otherwise it is rendered as a JSON entity.
+func Typed[In, Out any](fn func(context.Context, In) (Out, error)) Handler {
+	return func(req *Request, cxt Context) (*Response, error) {
+		in, err := bindInput[In](req, cxt)
+		if err != nil {
+			return nil, err
+		}
+		out, err := fn(req.Context(), in)
+		if err != nil {
+			return nil, err
+		}
+		switch v := any(out).(type) {
+		case *Response:
+			return v, nil
+		case Responder:
+			return v.Response(), nil
+		default:
+			return NewResponse(http.StatusOK).SetJSON(out)
+		}
+	}
+}
+
+// Populate an input value from a request
+func bindInput[In any](req *Request, cxt Context) (In, error) {
+	var in In
+	target := any(&in)
+	if t := reflect.TypeOf(in); t != nil && t.Kind() == reflect.Pointer {
+		in = reflect.New(t.Elem()).Interface().(In)
+		target = in
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		if err := req.Decode(target); err != nil {
+			return in, err
+		}
+	}
+	if reflect.TypeOf(target).Elem().Kind() != reflect.Struct {
+		return in, nil
+	}
+
+	query := req.URL.Query()
+	binds := []struct {
+		tag    string
+		lookup func(string) ([]string, bool)
+	}{
+		{"path", func(k string) ([]string, bool) {
+			v, ok := cxt.Vars[k]
+			return []string{v}, ok
+		}},
+		{"query", func(k string) ([]string, bool) {
+			v, ok := query[k]
+			return v, ok
+		}},
+		{"header", func(k string) ([]string, bool) {
+			v := req.Header.Values(k)
+			return v, len(v) > 0
+		}},
+	}
+	for _, b := range binds {
+		if err := entity.Bind(target, b.tag, b.lookup); err != nil {
+			return in, NewError(http.StatusBadRequest, "Invalid request parameters").SetCause(err) // the cause describes internals
+		}
+	}
+	return in, nil
+}
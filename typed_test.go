@@ -0,0 +1,87 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTyped(t *testing.T) {
+	type input struct {
+		ID    string `path:"id"`
+		Limit int    `query:"limit"`
+		Token string `header:"X-Token"`
+		Name  string `json:"name"`
+	}
+	type output struct {
+		ID    string `json:"id"`
+		Limit int    `json:"limit"`
+		Token string `json:"token"`
+		Name  string `json:"name"`
+	}
+
+	r := New()
+	r.Add("/a/{id}", Typed(func(cxt context.Context, in input) (output, error) {
+		return output(in), nil
+	})).Methods("GET", "POST")
+	r.Add("/b/{id}", Typed(func(cxt context.Context, in *input) (*Response, error) {
+		return NewResponse(http.StatusAccepted).SetString("text/plain", in.ID)
+	})).Methods("GET")
+	r.Add("/c", Typed(func(cxt context.Context, in []string) (Responder, error) {
+		return ResponderFunc(func() *Response {
+			rsp, _ := NewResponse(http.StatusCreated).SetString("text/plain", strings.Join(in, ","))
+			return rsp
+		}), nil
+	})).Methods("POST")
+	r.Add("/d", Typed(func(cxt context.Context, in struct{}) (output, error) {
+		return output{}, NewError(http.StatusConflict, "Conflict")
+	})).Methods("GET")
+
+	req, err := NewRequest("GET", "/a/123?limit=10", nil)
+	if assert.NoError(t, err) {
+		req.Header.Set("X-Token", "abc")
+		handleRoute(t, r, req, http.StatusOK, []byte(`{"id":"123","limit":10,"token":"abc","name":""}`), nil)
+	}
+	req, err = NewRequest("POST", "/a/123", strings.NewReader(`{"id":"nope","name":"A"}`))
+	if assert.NoError(t, err) {
+		req.Header.Set("Content-Type", "application/json")
+		handleRoute(t, r, req, http.StatusOK, []byte(`{"id":"123","limit":0,"token":"","name":"A"}`), nil)
+	}
+	req, err = NewRequest("GET", "/b/123", nil)
+	if assert.NoError(t, err) {
+		handleRoute(t, r, req, http.StatusAccepted, []byte("123"), nil)
+	}
+	req, err = NewRequest("POST", "/c", strings.NewReader(`["a","b"]`))
+	if assert.NoError(t, err) {
+		req.Header.Set("Content-Type", "application/json")
+		handleRoute(t, r, req, http.StatusCreated, []byte("a,b"), nil)
+	}
+
+	var herr *HTTPError
+	req, err = NewRequest("GET", "/a/123?limit=nope", nil)
+	if assert.NoError(t, err) {
+		_, err = r.Handle(req)
+		if assert.ErrorAs(t, err, &herr) {
+			assert.Equal(t, http.StatusBadRequest, herr.Status)
+			assert.Equal(t, "Invalid request parameters", herr.Message)
+			assert.Error(t, herr.Cause)
+		}
+	}
+	req, err = NewRequest("POST", "/a/123", strings.NewReader(`{}`))
+	if assert.NoError(t, err) {
+		_, err = r.Handle(req)
+		if assert.ErrorAs(t, err, &herr) {
+			assert.Equal(t, http.StatusUnsupportedMediaType, herr.Status)
+		}
+	}
+	req, err = NewRequest("GET", "/d", nil)
+	if assert.NoError(t, err) {
+		_, err = r.Handle(req)
+		if assert.ErrorAs(t, err, &herr) {
+			assert.Equal(t, http.StatusConflict, herr.Status)
+		}
+	}
+}
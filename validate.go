@@ -0,0 +1,334 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bww/go-router/v2/path"
+)
+
+// The kind of problem a route has
+type ConflictKind int
+
+const (
+	ConflictDuplicate ConflictKind = iota // the route duplicates another
+	ConflictShadowed                      // the route is shadowed by another
+	ConflictOverlap                       // some methods of the route are handled by another
+	ConflictNoMethods                     // the route matches any method
+	ConflictNoPaths                       // the route matches no paths
+)
+
+func (k ConflictKind) String() string {
+	switch k {
+	case ConflictDuplicate:
+		return "duplicate"
+	case ConflictShadowed:
+		return "shadowed"
+	case ConflictOverlap:
+		return "overlap"
+	case ConflictNoMethods:
+		return "no methods"
+	case ConflictNoPaths:
+		return "no paths"
+	default:
+		return "unknown"
+	}
+}
+
+// A problem with a route, as reported by Validate
+type Conflict struct {
+	Kind    ConflictKind
+	Route   *Route   // the route with the problem
+	Other   *Route   // the route which takes precedence over it, if any
+	Methods []string // the methods which are handled by the other route, for overlaps
+}
+
+func (c Conflict) String() string {
+	switch c.Kind {
+	case ConflictDuplicate:
+		return fmt.Sprintf("%v duplicates %v", c.Route, c.Other)
+	case ConflictShadowed:
+		return fmt.Sprintf("%v is shadowed by %v", c.Route, c.Other)
+	case ConflictOverlap:
+		return fmt.Sprintf("%v is shadowed by %v for %s", c.Route, c.Other, strings.Join(c.Methods, ", "))
+	case ConflictNoMethods:
+		return fmt.Sprintf("%v has no methods", c.Route)
+	case ConflictNoPaths:
+		return fmt.Sprintf("%v has no paths", c.Route)
+	default:
+		return fmt.Sprintf("%v has a problem: %v", c.Route, c.Kind)
+	}
+}
+
+// The error produced by Validate, which lists every problem found
+type ValidationError []Conflict
+
+func (e ValidationError) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("Invalid routes:")
+	for _, c := range e {
+		b.WriteString("\n  ")
+		b.WriteString(c.String())
+	}
+	return b.String()
+}
+
+// Validate checks the routes in this router for problems which are probably
+// mistakes and reports them as a ValidationError. The following problems are
+// reported:
+//
+//   - Duplicates: routes which match exactly the same requests as a route
+//     evaluated before them, for example, '/a/{x}' and '/a/{y}'.
+//   - Shadowed routes: routes which are unreachable; see Shadowed.
+//   - Overlaps: routes which are only reachable for some of their methods,
+//     because a route evaluated before them handles the others.
+//   - Routes with no methods, which match any method. Routes which mount
+//     other routers or handlers are expected to match any method.
+//   - Routes with no paths, which can never match.
+//
+// Like Shadowed, this check is conservative and ignores routes which use a
+// custom Matcher.
+func (r *router) Validate() error {
+	var res ValidationError
+	routes := r.load().routes
+	all := orderCandidates(routes, r.config.Order)
+	dups := make(map[*Route]*Route)
+	for i, a := range all {
+		for _, b := range all[i+1:] {
+			if a.route == b.route || dups[b.route] != nil {
+				continue
+			}
+			if a.path.Covers(b.path) && b.path.Covers(a.path) && a.route.subsumes(b.route) && b.route.subsumes(a.route) {
+				dups[b.route] = a.route
+			}
+		}
+	}
+
+	shadows := make(map[*Route]*Route)
+	for _, e := range r.Shadowed() {
+		shadows[e.Route] = e.By
+	}
+
+	for _, route := range routes {
+		if len(route.pathList()) == 0 {
+			res = append(res, Conflict{Kind: ConflictNoPaths, Route: route})
+		}
+		if len(route.methods) == 0 && route.mount == nil {
+			res = append(res, Conflict{Kind: ConflictNoMethods, Route: route})
+		}
+		if other := dups[route]; other != nil {
+			res = append(res, Conflict{Kind: ConflictDuplicate, Route: route, Other: other})
+		} else if other := shadows[route]; other != nil {
+			res = append(res, Conflict{Kind: ConflictShadowed, Route: route, Other: other})
+		} else {
+			res = append(res, overlaps(route, all)...)
+		}
+	}
+	if len(res) > 0 {
+		return res
+	}
+	return nil
+}
+
+// Validate checks the routes added through this subrouter for problems
+func (r *subrouter) Validate() error {
+	err := r.root.Validate()
+	all, ok := err.(ValidationError)
+	if !ok {
+		return err
+	}
+	var res ValidationError
+	for _, e := range all {
+		if e.Route.scope.within(r) {
+			res = append(res, e)
+		}
+	}
+	if len(res) > 0 {
+		return res
+	}
+	return nil
+}
+
+// Find the routes which take precedence over the provided route for some,
+// but not all, of its methods on one of its paths
+func overlaps(route *Route, all []candidate) []Conflict {
+	if len(route.methods) == 0 {
+		return nil
+	}
+	var res []Conflict
+	seen := make(map[*Route]struct{})
+	for _, p := range route.pathList() {
+		for _, e := range all {
+			if e.route == route && e.path.String() == p.String() {
+				break // nothing after this path takes precedence
+			}
+			if _, ok := seen[e.route]; ok || e.route == route || len(e.route.methods) == 0 {
+				continue
+			}
+			if !e.path.Covers(p) || !e.route.subsumesConditions(route) {
+				continue
+			}
+			var methods []string
+			for k := range route.methods {
+				if _, ok := e.route.methods[k]; ok {
+					methods = append(methods, strings.ToUpper(k))
+				}
+			}
+			if len(methods) > 0 {
+				sort.Strings(methods)
+				seen[e.route] = struct{}{}
+				res = append(res, Conflict{Kind: ConflictOverlap, Route: route, Other: e.route, Methods: methods})
+			}
+		}
+	}
+	return res
+}
+
+// A route which can never be matched because another route, which takes
+// precedence over it, matches every request it would match
+type Shadow struct {
+	Route *Route // the unreachable route
+	By    *Route // the route which takes precedence
+}
+
+// Shadowed reports routes which can never be reached because routes which
+// are evaluated before them match every request they would match. The order
+// in which routes are evaluated is the one configured for the router.
+//
+// This check is conservative: a route is only reported when it can be
+// determined from route definitions alone that it is unreachable. Routes
+// which use a custom Matcher never shadow other routes.
+func (r *router) Shadowed() []Shadow {
+	routes := r.load().routes
+	all := orderCandidates(routes, r.config.Order)
+	var res []Shadow
+	for _, route := range routes {
+		if len(route.pathList()) == 0 {
+			continue
+		}
+		var by *Route
+		for _, p := range route.pathList() {
+			var cover *Route
+			for _, e := range all {
+				if e.route == route && e.path.String() == p.String() {
+					break // we've reached this path; nothing after it takes precedence
+				}
+				if e.route != route && e.route.subsumes(route) && e.path.Covers(p) {
+					cover = e.route
+					break
+				}
+			}
+			if cover == nil {
+				by = nil
+				break
+			}
+			if by == nil {
+				by = cover
+			}
+		}
+		if by != nil {
+			res = append(res, Shadow{Route: route, By: by})
+		}
+	}
+	return res
+}
+
+// Shadowed reports unreachable routes which were added through this subrouter
+func (r *subrouter) Shadowed() []Shadow {
+	var res []Shadow
+	for _, e := range r.root.Shadowed() {
+		if e.Route.scope.within(r) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Does this route match every request the provided route matches, without
+// considering paths
+func (r *Route) subsumes(o *Route) bool {
+	if r.methods != nil {
+		if o.methods == nil {
+			return false
+		}
+		for k := range o.methods {
+			if _, ok := r.methods[k]; !ok {
+				return false
+			}
+		}
+	}
+	return r.subsumesConditions(o)
+}
+
+// Does this route match every request the provided route matches, without
+// considering paths or methods
+func (r *Route) subsumesConditions(o *Route) bool {
+	if r.mount != nil || r.matcher != nil {
+		return false
+	}
+	for k, v := range r.params {
+		if !equalValues(o.params[k], v) {
+			return false
+		}
+	}
+	for k, v := range r.headers {
+		for _, e := range v {
+			if !contains(o.headers.Values(k), e) {
+				return false
+			}
+		}
+	}
+	if !subsumesMedia(r.consumes, o.consumes) || !subsumesMedia(r.produces, o.produces) {
+		return false
+	}
+	if len(r.hosts) > 0 {
+		if len(o.hosts) == 0 {
+			return false
+		}
+		for _, h := range o.hosts {
+			if !coveredBy(h, r.hosts) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Does every media type in the second set match a range in the first. An
+// empty set matches anything.
+func subsumesMedia(r, o []string) bool {
+	if len(r) == 0 {
+		return true
+	} else if len(o) == 0 {
+		return false
+	}
+	for _, e := range o {
+		if !anyMediaMatches(r, e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Is the path covered by any of the provided paths
+func coveredBy(p path.Path, c []path.Path) bool {
+	for _, e := range c {
+		if e.Covers(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}